@@ -0,0 +1,461 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/forms"
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// The JSON API lives under /api/v1 and mirrors what the HTML pages can do with
+// snippets. Every response body is a JSON object: successful responses wrap
+// their data in a named key, like {"snippet": {...}}, and failed ones carry
+// an "error" message, plus a "fields" object for validation failures.
+//
+// The API routes don't use sessions or the nosurf middleware. Instead,
+// requests which change anything must carry one of the user's API tokens in
+// an Authorization header, which a browser never adds to a cross-site request
+// by itself.
+
+// The envelope type is used for the top-level object of every response.
+type envelope map[string]interface{}
+
+// apiSnippet is the JSON representation of a snippet. We keep it separate
+// from models.Snippet so that the API only ever exposes the fields listed
+// here.
+type apiSnippet struct {
+	ID         int       `json:"id"`
+	Slug       string    `json:"slug"`
+	Author     string    `json:"author,omitempty"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Language   string    `json:"language"`
+	Visibility string    `json:"visibility"`
+	Encrypted  bool      `json:"encrypted"`
+	Created    time.Time `json:"created"`
+
+	// Expires is null for snippets which never expire.
+	Expires *time.Time `json:"expires"`
+}
+
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	as := apiSnippet{
+		ID:         s.ID,
+		Slug:       s.Slug,
+		Author:     s.Author,
+		Title:      s.Title,
+		Content:    s.Content,
+		Language:   s.Language,
+		Visibility: s.Visibility,
+		Encrypted:  s.Encrypted,
+		Created:    s.Created,
+	}
+	if !s.Expires.IsZero() {
+		as.Expires = &s.Expires
+	}
+	return as
+}
+
+// The default and maximum number of snippets on each page of
+// GET /api/v1/snippets and GET /api/v1/search.
+const (
+	apiDefaultPerPage = 20
+	apiMaxPerPage     = 100
+)
+
+// The list is paged with cursors rather than page numbers: the metadata holds
+// next_cursor and prev_cursor when there are more snippets, which go in the
+// after and before query parameters of the next request.
+//
+// curl https://localhost:4000/api/v1/snippets?sort=oldest&per_page=10&after=$CURSOR
+func (a *application) apiListSnippets(w http.ResponseWriter, r *http.Request) {
+	perPage, ok := a.apiPerPage(w, r)
+	if !ok {
+		return
+	}
+
+	q, err := listQuery(r, perPage)
+	if err != nil {
+		a.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p, err := a.listSnippets(q)
+	if err != nil {
+		a.apiServerError(w, err)
+		return
+	}
+
+	snippets := make([]apiSnippet, len(p.Snippets))
+	for i := range p.Snippets {
+		snippets[i] = newAPISnippet(p.Snippets[i])
+	}
+
+	metadata := envelope{"sort": q.Sort, "per_page": perPage}
+	if p.Next != "" {
+		metadata["next_cursor"] = p.Next
+	}
+	if p.Prev != "" {
+		metadata["prev_cursor"] = p.Prev
+	}
+
+	a.writeJSON(w, http.StatusOK, envelope{"snippets": snippets, "metadata": metadata})
+}
+
+// apiSearchResult is a snippet in the search results, together with an
+// excerpt of its content in which the search terms are wrapped in <mark>
+// elements. The excerpt is HTML, with the rest of the content escaped.
+type apiSearchResult struct {
+	apiSnippet
+	Excerpt string `json:"excerpt"`
+}
+
+// curl https://localhost:4000/api/v1/search?q=nginx&language=bash&author=Alice
+func (a *application) apiSearchSnippets(w http.ResponseWriter, r *http.Request) {
+	page, perPage, ok := a.apiPage(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(r.URL.Query())
+	form.Required("q")
+	validateSearch(form)
+	if !form.Valid() {
+		a.apiValidationError(w, form)
+		return
+	}
+
+	s, err := a.snippets.Search(searchQuery(form), perPage+1, (page-1)*perPage)
+	if err != nil {
+		a.apiServerError(w, err)
+		return
+	}
+
+	s, metadata := paginate(s, page, perPage)
+
+	results := make([]apiSearchResult, len(s))
+	for i := range s {
+		results[i] = apiSearchResult{
+			apiSnippet: newAPISnippet(s[i]),
+			Excerpt:    string(excerpt(s[i].Content, form.Get("q"))),
+		}
+	}
+
+	a.writeJSON(w, http.StatusOK, envelope{"snippets": results, "metadata": metadata})
+}
+
+// curl https://localhost:4000/api/v1/snippets/1
+func (a *application) apiShowSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.apiSnippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, envelope{"snippet": newAPISnippet(s)})
+}
+
+// curl -H "Authorization: Bearer $TOKEN" -d '{"title": "Hello", "content": "...", "expires": 7}' https://localhost:4000/api/v1/snippets
+func (a *application) apiCreateSnippet(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title      string      `json:"title"`
+		Content    string      `json:"content"`
+		Language   string      `json:"language"`
+		Visibility string      `json:"visibility"`
+		Encrypted  bool        `json:"encrypted"`
+		Expires    interface{} `json:"expires"`
+		ExpiresAt  string      `json:"expires_at"`
+	}
+
+	if err := readJSON(w, r, &input); err != nil {
+		a.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Validate the input with exactly the same rules as the create form, by
+	// turning it into the form values the HTML page would have posted. A
+	// missing expires is left blank, so that it's reported as such.
+	values := url.Values{}
+	values.Set("title", input.Title)
+	values.Set("content", input.Content)
+	values.Set("language", input.Language)
+	values.Set("visibility", input.Visibility)
+	if input.Encrypted {
+		values.Set("encrypted", "true")
+	}
+
+	// The expiry can be a number of days, as it always could, or a string:
+	// either a duration like "1h" or "1mo", "never", or "custom" together
+	// with an expires_at time in the same format as the create form's.
+	values.Set("expires_at", input.ExpiresAt)
+	switch expires := input.Expires.(type) {
+	case nil:
+	case float64:
+		values.Set("expires", fmt.Sprintf("%gd", expires))
+	case string:
+		values.Set("expires", expires)
+	default:
+		a.apiError(w, http.StatusBadRequest, `body contains the wrong type for the "expires" field`)
+		return
+	}
+
+	form := forms.New(values)
+	validateNewSnippet(form)
+	if !form.Valid() {
+		a.apiValidationError(w, form)
+		return
+	}
+
+	id, err := a.snippets.Insert(a.authenticatedUser(r).ID, form.Get("title"), form.Get("content"), form.Get("language"), snippetExpiry(form), snippetOptions(form))
+	if err != nil {
+		a.apiServerError(w, err)
+		return
+	}
+
+	s, err := a.snippets.Get(id)
+	if err != nil {
+		a.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	a.writeJSON(w, http.StatusCreated, envelope{"snippet": newAPISnippet(s)})
+}
+
+// curl -H "Authorization: Bearer $TOKEN" -X PUT -d '{"title": "Hello", "content": "..."}' https://localhost:4000/api/v1/snippets/1
+func (a *application) apiUpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if s.Encrypted {
+		a.apiError(w, http.StatusUnprocessableEntity, "encrypted snippets can't be edited")
+		return
+	}
+
+	var input struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+
+	if err := readJSON(w, r, &input); err != nil {
+		a.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	values := url.Values{}
+	values.Set("title", input.Title)
+	values.Set("content", input.Content)
+
+	form := forms.New(values)
+	validateSnippetEdit(form)
+	if !form.Valid() {
+		a.apiValidationError(w, form)
+		return
+	}
+
+	if err := a.snippets.Update(s.ID, a.authenticatedUser(r).ID, form.Get("title"), form.Get("content")); err != nil {
+		a.apiServerError(w, err)
+		return
+	}
+
+	s.Title = form.Get("title")
+	s.Content = form.Get("content")
+
+	a.writeJSON(w, http.StatusOK, envelope{"snippet": newAPISnippet(s)})
+}
+
+// curl -H "Authorization: Bearer $TOKEN" -X DELETE https://localhost:4000/api/v1/snippets/1
+func (a *application) apiDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.snippets.Delete(s.ID)
+	if err == models.ErrNoRecord {
+		a.apiNotFound(w)
+		return
+	} else if err != nil {
+		a.apiServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The apiSnippetFromURL helper is the API's version of snippetFromURL, which
+// sends its errors as JSON.
+func (a *application) apiSnippetFromURL(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		a.apiNotFound(w)
+		return nil, false
+	}
+
+	// The API only looks snippets up by id, so unlisted snippets are just
+	// as hidden here as private ones.
+	s, err := a.snippets.Get(id)
+	if err == models.ErrNoRecord || (err == nil && !canView(a.authenticatedUser(r), s, false)) {
+		a.apiNotFound(w)
+		return nil, false
+	} else if err != nil {
+		a.apiServerError(w, err)
+		return nil, false
+	}
+
+	return s, true
+}
+
+// The apiOwnedSnippet helper is the API's version of ownedSnippet.
+func (a *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, ok := a.apiSnippetFromURL(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	user := a.authenticatedUser(r)
+	if user == nil || user.ID != s.UserID {
+		a.apiError(w, http.StatusForbidden, "you do not own this snippet")
+		return nil, false
+	}
+
+	return s, true
+}
+
+// The apiPage helper reads the page and per_page query parameters. If either
+// is invalid it sends a 400 Bad Request response and ok is false.
+func (a *application) apiPage(w http.ResponseWriter, r *http.Request) (page, perPage int, ok bool) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		a.apiError(w, http.StatusBadRequest, "page must be a positive integer")
+		return 0, 0, false
+	}
+
+	perPage, ok = a.apiPerPage(w, r)
+	return page, perPage, ok
+}
+
+// The apiPerPage helper reads the per_page query parameter on its own, for
+// the lists which are paged with cursors instead of page numbers.
+func (a *application) apiPerPage(w http.ResponseWriter, r *http.Request) (int, bool) {
+	perPage, err := queryInt(r, "per_page", apiDefaultPerPage)
+	if err != nil || perPage < 1 || perPage > apiMaxPerPage {
+		a.apiError(w, http.StatusBadRequest, fmt.Sprintf("per_page must be between 1 and %d", apiMaxPerPage))
+		return 0, false
+	}
+
+	return perPage, true
+}
+
+// paginate takes the snippets fetched for a page, which should include one
+// extra snippet if there's a next page, and returns the snippets to show
+// together with the "metadata" object describing the page.
+func paginate(s []*models.Snippet, page, perPage int) ([]*models.Snippet, envelope) {
+	metadata := envelope{"page": page, "per_page": perPage}
+	if len(s) > perPage {
+		s = s[:perPage]
+		metadata["next_page"] = page + 1
+	}
+	return s, metadata
+}
+
+// The writeJSON helper sends data as a JSON response with the given status.
+func (a *application) writeJSON(w http.ResponseWriter, status int, data envelope) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// The apiError helper sends an error message as JSON. It's the API's
+// equivalent of clientError.
+func (a *application) apiError(w http.ResponseWriter, status int, message string) {
+	a.writeJSON(w, status, envelope{"error": message})
+}
+
+// The apiServerError helper logs err like serverError does, but sends the
+// generic 500 response as JSON.
+func (a *application) apiServerError(w http.ResponseWriter, err error) {
+	a.errorLog.Output(2, err.Error())
+	a.apiError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// The apiUnauthorized helper tells the client that it must authenticate, and
+// how.
+func (a *application) apiUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	a.apiError(w, http.StatusUnauthorized, "invalid or missing authentication credentials")
+}
+
+func (a *application) apiNotFound(w http.ResponseWriter) {
+	a.apiError(w, http.StatusNotFound, "the requested snippet could not be found")
+}
+
+// The apiValidationError helper sends the errors collected by a form, keyed
+// by field name, with a 422 Unprocessable Entity status.
+func (a *application) apiValidationError(w http.ResponseWriter, form *forms.Form) {
+	a.writeJSON(w, http.StatusUnprocessableEntity, envelope{
+		"error":  "the request contains invalid fields",
+		"fields": form.Errors,
+	})
+}
+
+// readJSON decodes a request body containing a single JSON object into dst.
+// Bodies over 1MB, unknown fields and trailing data are all rejected, with
+// an error message which is fit to send back to the client.
+func readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+
+		switch {
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case errors.As(err, &syntaxError), errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &typeError):
+			return fmt.Errorf("body contains the wrong type for the %q field", typeError.Field)
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case err.Error() == "http: request body too large":
+			return errors.New("body must not be larger than 1MB")
+		default:
+			return err
+		}
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON object")
+	}
+
+	return nil
+}
+
+// queryInt reads an integer from the URL query string, returning def if the
+// parameter isn't present.
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestAPIListSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	for i := 0; i < 3; i++ {
+		if _, err := app.snippets.Insert(1, "Title", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	type metadata struct {
+		NextCursor string `json:"next_cursor"`
+		PrevCursor string `json:"prev_cursor"`
+	}
+
+	// list fetches urlPath and checks that the response lists wantIDs.
+	list := func(t *testing.T, urlPath string, wantIDs []int) metadata {
+		code, header, body := ts.apiRequest(t, http.MethodGet, urlPath, "", "")
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+		if ct := header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("want application/json content type; got %q", ct)
+		}
+
+		var rs struct {
+			Snippets []apiSnippet
+			Metadata metadata
+		}
+		if err := json.Unmarshal(body, &rs); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rs.Snippets) != len(wantIDs) {
+			t.Fatalf("want %d snippets; got %d", len(wantIDs), len(rs.Snippets))
+		}
+		for i, id := range wantIDs {
+			if rs.Snippets[i].ID != id {
+				t.Errorf("want snippet %d to be #%d; got #%d", i, id, rs.Snippets[i].ID)
+			}
+		}
+		return rs.Metadata
+	}
+
+	t.Run("Default page", func(t *testing.T) {
+		md := list(t, "/api/v1/snippets", []int{3, 2, 1})
+		if md.NextCursor != "" || md.PrevCursor != "" {
+			t.Errorf("want no cursors; got %+v", md)
+		}
+	})
+
+	t.Run("Oldest first", func(t *testing.T) {
+		list(t, "/api/v1/snippets?sort=oldest", []int{1, 2, 3})
+	})
+
+	t.Run("Following cursors", func(t *testing.T) {
+		first := list(t, "/api/v1/snippets?per_page=2", []int{3, 2})
+		if first.NextCursor == "" || first.PrevCursor != "" {
+			t.Fatalf("want only a next cursor; got %+v", first)
+		}
+
+		second := list(t, "/api/v1/snippets?per_page=2&after="+first.NextCursor, []int{1})
+		if second.NextCursor != "" || second.PrevCursor == "" {
+			t.Fatalf("want only a previous cursor; got %+v", second)
+		}
+
+		back := list(t, "/api/v1/snippets?per_page=2&before="+second.PrevCursor, []int{3, 2})
+		if back.NextCursor == "" || back.PrevCursor != "" {
+			t.Errorf("want only a next cursor; got %+v", back)
+		}
+	})
+
+	for _, urlPath := range []string{
+		"/api/v1/snippets?per_page=1000",
+		"/api/v1/snippets?sort=random",
+		"/api/v1/snippets?after=not-a-cursor",
+	} {
+		t.Run("Invalid "+urlPath, func(t *testing.T) {
+			code, _, body := ts.apiRequest(t, http.MethodGet, urlPath, "", "")
+			if code != http.StatusBadRequest {
+				t.Fatalf("want %d; got %d", http.StatusBadRequest, code)
+			}
+
+			var rs struct{ Error string }
+			if err := json.Unmarshal(body, &rs); err != nil {
+				t.Fatal(err)
+			}
+			if rs.Error == "" {
+				t.Errorf("want an error message")
+			}
+		})
+	}
+}
+
+func TestAPISearchSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	if _, err := app.snippets.Insert(1, "Reload nginx", "sudo systemctl reload nginx", "bash", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.snippets.Insert(1, "Docker", "docker ps", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.apiRequest(t, http.MethodGet, "/api/v1/search?q=nginx", "", "")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	var rs struct{ Snippets []apiSearchResult }
+	if err := json.Unmarshal(body, &rs); err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Snippets) != 1 || rs.Snippets[0].ID != 1 {
+		t.Fatalf("unexpected results %+v", rs.Snippets)
+	}
+	if want := "sudo systemctl reload <mark>nginx</mark>"; rs.Snippets[0].Excerpt != want {
+		t.Errorf("want excerpt %q; got %q", want, rs.Snippets[0].Excerpt)
+	}
+
+	code, _, _ = ts.apiRequest(t, http.MethodGet, "/api/v1/search", "", "")
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("missing query: want %d; got %d", http.StatusUnprocessableEntity, code)
+	}
+}
+
+func TestAPIShowSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	if _, err := app.snippets.Insert(1, "An old silent pond", "An old silent pond...", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.apiRequest(t, http.MethodGet, "/api/v1/snippets/1", "", "")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	var rs struct{ Snippet apiSnippet }
+	if err := json.Unmarshal(body, &rs); err != nil {
+		t.Fatal(err)
+	}
+	if rs.Snippet.ID != 1 || rs.Snippet.Title != "An old silent pond" {
+		t.Errorf("unexpected snippet %+v", rs.Snippet)
+	}
+	if path := snippetPath(t, app, 1); "/s/"+rs.Snippet.Slug != path {
+		t.Errorf("want slug of %s; got %q", path, rs.Snippet.Slug)
+	}
+
+	for _, urlPath := range []string{"/api/v1/snippets/2", "/api/v1/snippets/foo"} {
+		code, _, body := ts.apiRequest(t, http.MethodGet, urlPath, "", "")
+		if code != http.StatusNotFound {
+			t.Errorf("%s: want %d; got %d", urlPath, http.StatusNotFound, code)
+		}
+		if string(body) != `{"error":"the requested snippet could not be found"}`+"\n" {
+			t.Errorf("%s: unexpected body %s", urlPath, body)
+		}
+	}
+}
+
+func TestAPICreateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	alice := newTestToken(t, app, "Alice", "alice@example.com")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		body       string
+		token      string
+		wantCode   int
+		wantFields []string
+	}{
+		{"Valid", `{"title": "Hello", "content": "package main", "language": "go", "expires": 7}`, alice, http.StatusCreated, nil},
+		{"Unauthenticated", `{"title": "Hello", "content": "package main", "expires": 7}`, "", http.StatusUnauthorized, nil},
+		{"Invalid token", `{"title": "Hello", "content": "package main", "expires": 7}`, "not-a-token", http.StatusUnauthorized, nil},
+		{"Empty body", ``, alice, http.StatusBadRequest, nil},
+		{"Badly-formed JSON", `{"title": `, alice, http.StatusBadRequest, nil},
+		{"Unknown field", `{"title": "Hello", "colour": "red"}`, alice, http.StatusBadRequest, nil},
+		{"Invalid fields", `{"title": "", "content": "x", "language": "klingon", "expires": "2x"}`, alice, http.StatusUnprocessableEntity, []string{"title", "language", "expires"}},
+		{"Missing expires", `{"title": "Hello", "content": "x"}`, alice, http.StatusUnprocessableEntity, []string{"expires"}},
+		{"Expires of the wrong type", `{"title": "Hello", "content": "x", "expires": true}`, alice, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", tt.body, tt.token)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d: %s", tt.wantCode, code, body)
+			}
+
+			var rs struct {
+				Snippet apiSnippet
+				Error   string
+				Fields  map[string][]string
+			}
+			if err := json.Unmarshal(body, &rs); err != nil {
+				t.Fatal(err)
+			}
+
+			switch code {
+			case http.StatusCreated:
+				if rs.Snippet.Author != "Alice" || rs.Snippet.Language != "go" {
+					t.Errorf("unexpected snippet %+v", rs.Snippet)
+				}
+				if loc := header.Get("Location"); loc != "/api/v1/snippets/1" {
+					t.Errorf("want Location /api/v1/snippets/1; got %q", loc)
+				}
+			case http.StatusUnauthorized:
+				if header.Get("WWW-Authenticate") == "" {
+					t.Errorf("want a WWW-Authenticate header")
+				}
+			}
+
+			if code != http.StatusCreated && rs.Error == "" {
+				t.Errorf("want an error message")
+			}
+			for _, field := range tt.wantFields {
+				if len(rs.Fields[field]) == 0 {
+					t.Errorf("want an error for the %q field", field)
+				}
+			}
+		})
+	}
+}
+
+func TestAPICreateSnippetExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	alice := newTestToken(t, app, "Alice", "alice@example.com")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	now := time.Now()
+	custom := now.Add(48 * time.Hour).UTC().Format(expiresAtLayout)
+
+	tests := []struct {
+		name        string
+		expires     string
+		wantExpires time.Time
+	}{
+		{"Days", `7`, now.AddDate(0, 0, 7)},
+		{"Duration", `"1h"`, now.Add(time.Hour)},
+		{"Never", `"never"`, time.Time{}},
+		{"Custom", `"custom", "expires_at": "` + custom + `"`, now.Add(48 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", `{"title": "Hello", "content": "World", "expires": `+tt.expires+`}`, alice)
+			if code != http.StatusCreated {
+				t.Fatalf("want %d; got %d: %s", http.StatusCreated, code, body)
+			}
+
+			// Snippets which never expire have a null expiry time.
+			var rs struct{ Snippet apiSnippet }
+			if err := json.Unmarshal(body, &rs); err != nil {
+				t.Fatal(err)
+			}
+			got := rs.Snippet.Expires
+			if tt.wantExpires.IsZero() {
+				if got != nil {
+					t.Errorf("want a null expiry time; got %v", got)
+				}
+			} else if got == nil || got.Sub(tt.wantExpires) > time.Minute || tt.wantExpires.Sub(*got) > time.Minute {
+				t.Errorf("want the snippet to expire at %v; got %v", tt.wantExpires, got)
+			}
+		})
+	}
+
+	// A custom expiry needs an expires_at time, which is reported like any
+	// other invalid field.
+	code, _, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", `{"title": "Hello", "content": "World", "expires": "custom"}`, alice)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("want %d; got %d: %s", http.StatusUnprocessableEntity, code, body)
+	}
+	var rs struct{ Fields map[string][]string }
+	if err := json.Unmarshal(body, &rs); err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Fields["expires_at"]) == 0 {
+		t.Errorf("want an error for expires_at; got %v", rs.Fields)
+	}
+}
+
+func TestAPIUpdateAndDeleteSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	alice := newTestToken(t, app, "Alice", "alice@example.com")
+	bob := newTestToken(t, app, "Bob", "bob@example.com")
+	if _, err := app.snippets.Insert(1, "Title", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	update := `{"title": "New title", "content": "New content"}`
+
+	code, _, _ := ts.apiRequest(t, http.MethodPut, "/api/v1/snippets/1", update, bob)
+	if code != http.StatusForbidden {
+		t.Errorf("update by another user: want %d; got %d", http.StatusForbidden, code)
+	}
+
+	code, _, _ = ts.apiRequest(t, http.MethodPut, "/api/v1/snippets/1", `{"title": ""}`, alice)
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid update: want %d; got %d", http.StatusUnprocessableEntity, code)
+	}
+
+	code, _, body := ts.apiRequest(t, http.MethodPut, "/api/v1/snippets/1", update, alice)
+	if code != http.StatusOK {
+		t.Fatalf("update: want %d; got %d", http.StatusOK, code)
+	}
+	var rs struct{ Snippet apiSnippet }
+	if err := json.Unmarshal(body, &rs); err != nil {
+		t.Fatal(err)
+	}
+	if rs.Snippet.Title != "New title" || rs.Snippet.Content != "New content" {
+		t.Errorf("unexpected snippet %+v", rs.Snippet)
+	}
+
+	code, _, _ = ts.apiRequest(t, http.MethodDelete, "/api/v1/snippets/1", "", bob)
+	if code != http.StatusForbidden {
+		t.Errorf("delete by another user: want %d; got %d", http.StatusForbidden, code)
+	}
+
+	code, _, _ = ts.apiRequest(t, http.MethodDelete, "/api/v1/snippets/1", "", alice)
+	if code != http.StatusNoContent {
+		t.Errorf("delete: want %d; got %d", http.StatusNoContent, code)
+	}
+
+	code, _, _ = ts.apiRequest(t, http.MethodGet, "/api/v1/snippets/1", "", "")
+	if code != http.StatusNotFound {
+		t.Errorf("after delete: want %d; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	app := newTestApplication(t)
+	valid := newTestToken(t, app, "Alice", "alice@example.com")
+
+	expired, err := app.tokens.Insert(1, "Expired", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := app.tokens.Insert(1, "Revoked", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.tokens.Delete(3, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	body := `{"title": "Hello", "content": "package main", "expires": 7}`
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+	}{
+		{"Valid token", "Bearer " + valid, http.StatusCreated},
+		{"Lower case scheme", "bearer " + valid, http.StatusCreated},
+		{"Expired token", "Bearer " + expired, http.StatusUnauthorized},
+		{"Revoked token", "Bearer " + revoked, http.StatusUnauthorized},
+		{"Basic auth", "Basic YWxpY2VAZXhhbXBsZS5jb206dmFsaWRQYSQkd29yZA==", http.StatusUnauthorized},
+		{"No header", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/snippets", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+		})
+	}
+
+	// Using a token records when it was last used.
+	tokens, err := app.tokens.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range tokens {
+		if tok.Name == "Alice" && tok.LastUsed.IsZero() {
+			t.Errorf("want the token's last used time to be recorded")
+		}
+	}
+}
+
+// newTestToken creates a user with the given name and email, and returns a
+// new API token for them.
+func TestAPISnippetVisibility(t *testing.T) {
+	app := newTestApplication(t)
+	alice := newTestToken(t, app, "Alice", "alice@example.com")
+	bob := newTestToken(t, app, "Bob", "bob@example.com")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", `{"title": "Hello", "content": "World", "visibility": "unlisted", "expires": 7}`, alice)
+	if code != http.StatusCreated {
+		t.Fatalf("want %d; got %d", http.StatusCreated, code)
+	}
+	var rs struct{ Snippet apiSnippet }
+	if err := json.Unmarshal(body, &rs); err != nil {
+		t.Fatal(err)
+	}
+	if rs.Snippet.Visibility != models.VisibilityUnlisted {
+		t.Errorf("want visibility %q; got %q", models.VisibilityUnlisted, rs.Snippet.Visibility)
+	}
+
+	// The API only knows snippets by id, so an unlisted snippet is only
+	// visible to its owner there, and it's never listed.
+	path := fmt.Sprintf("/api/v1/snippets/%d", rs.Snippet.ID)
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		body     string
+		token    string
+		wantCode int
+	}{
+		{"Owner", http.MethodGet, path, "", alice, http.StatusOK},
+		{"Someone else", http.MethodGet, path, "", bob, http.StatusNotFound},
+		{"Anonymous", http.MethodGet, path, "", "", http.StatusNotFound},
+		{"Invalid visibility", http.MethodPost, "/api/v1/snippets", `{"title": "Hello", "content": "World", "visibility": "secret", "expires": 7}`, alice, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.apiRequest(t, tt.method, tt.urlPath, tt.body, tt.token)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	_, _, body = ts.apiRequest(t, http.MethodGet, "/api/v1/snippets", "", alice)
+	if bytes.Contains(body, []byte(`"Hello"`)) {
+		t.Errorf("want the unlisted snippet left out of the list")
+	}
+}
+
+func newTestToken(t *testing.T, app *application, name, email string) string {
+	if err := app.users.Insert(name, email, "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := app.users.Authenticate(email, "validPa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := app.tokens.Insert(id, name, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestAPIEncryptedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	alice := newTestToken(t, app, "Alice", "alice@example.com")
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// API clients encrypt the content themselves, so it has to look like
+	// ciphertext.
+	code, _, _ := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", `{"title": "Secret", "content": "not encrypted", "encrypted": true, "expires": 7}`, alice)
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("want %d; got %d", http.StatusUnprocessableEntity, code)
+	}
+
+	code, _, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", `{"title": "Secret", "content": "q83vEjRWeJq8_-3u", "encrypted": true, "expires": 7}`, alice)
+	if code != http.StatusCreated {
+		t.Fatalf("want %d; got %d", http.StatusCreated, code)
+	}
+	var rs struct{ Snippet apiSnippet }
+	if err := json.Unmarshal(body, &rs); err != nil {
+		t.Fatal(err)
+	}
+	if !rs.Snippet.Encrypted || rs.Snippet.Visibility != models.VisibilityUnlisted {
+		t.Errorf("want an unlisted encrypted snippet; got %+v", rs.Snippet)
+	}
+
+	path := fmt.Sprintf("/api/v1/snippets/%d", rs.Snippet.ID)
+	code, _, _ = ts.apiRequest(t, http.MethodPut, path, `{"title": "Secret", "content": "q83vEjRWeJq8"}`, alice)
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("want %d for an update; got %d", http.StatusUnprocessableEntity, code)
+	}
+}
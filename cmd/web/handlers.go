@@ -1,11 +1,16 @@
 package main
 
 import (
-	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/petrostrak/code-snippet/pkg/diff"
 	"github.com/petrostrak/code-snippet/pkg/forms"
+	"github.com/petrostrak/code-snippet/pkg/highlight"
 	"github.com/petrostrak/code-snippet/pkg/models"
 )
 
@@ -22,37 +27,96 @@ func (a *application) home(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tags, err := a.snippets.Tags(tagCloudSize)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	starred, err := a.snippets.MostStarred(time.Now().AddDate(0, 0, -7), mostStarredSize)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
 	// Use the new render helper.
 	a.render(w, r, "home.page.tmpl", &templateData{
-		Snippets: s,
+		MostStarred: starred,
+		Snippets:    s,
+		Tags:        tags,
 	})
 }
 
+// The number of tags in the home page's tag cloud, and of snippets in its
+// most starred this week list.
+const (
+	tagCloudSize    = 30
+	mostStarredSize = 5
+)
+
 // Add a showSnippet handler function.
 func (a *application) showSnippet(w http.ResponseWriter, r *http.Request) {
-
-	// Extract the value of the id parameter from the query string
-	// and try to convert it to an integer using the strconv.Atoi()
-	// function. If it cannot be converted to an integer of the value
-	// is less that 1, we return a 404 not found response.
-	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
-	if err != nil || id < 1 {
-		a.notFound(w)
+	s, ok := a.snippetFromURL(w, r)
+	if !ok {
 		return
 	}
 
-	s, err := a.snippets.Get(id)
-	if err == models.ErrNoRecord {
-		a.notFound(w)
+	// A protected snippet asks for its password first. Nothing about it is
+	// shown until then, not even its title.
+	if !a.snippetUnlocked(r, s) {
+		a.render(w, r, "unlock.page.tmpl", &templateData{
+			Form:    forms.New(nil),
+			Snippet: s,
+		})
 		return
-	} else if err != nil {
+	}
+
+	forks, err := a.snippets.CountForks(s.ID)
+	if err != nil {
 		a.serverError(w, err)
 		return
 	}
 
-	// Use the render helper
+	// The snippet this one was forked from is only linked to if the user
+	// could have found it by its id, so that forking an unlisted or private
+	// snippet doesn't give its slug away. Otherwise, or if it has been
+	// deleted, just its number is shown.
+	var original *models.Snippet
+	if s.ForkedFrom != 0 {
+		original, err = a.snippets.Get(s.ForkedFrom)
+		if err != nil && err != models.ErrNoRecord {
+			a.serverError(w, err)
+			return
+		}
+		if original != nil && !canView(a.authenticatedUser(r), original, false) {
+			original = nil
+		}
+	}
+
+	var starred bool
+	if user := a.authenticatedUser(r); user != nil {
+		if starred, err = a.snippets.IsStarred(user.ID, s.ID); err != nil {
+			a.serverError(w, err)
+			return
+		}
+	}
+
+	// Reading a burn-after-reading snippet can't be undone, so it's left
+	// until everything else which could fail has been looked up.
+	burned, ok := a.readSnippet(w, r, s)
+	if !ok {
+		return
+	}
+
+	// Markdown snippets are rendered to HTML unless ?view=source asks to see
+	// what was actually written.
 	a.render(w, r, "show.page.tmpl", &templateData{
-		Snippet: s,
+		Burned:     burned,
+		Forks:      forks,
+		Original:   original,
+		ShowSource: r.URL.Query().Get("view") == "source",
+		Snippet:    s,
+		Starred:    starred,
 	})
 
 }
@@ -70,24 +134,39 @@ func (a *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// Create a new forms.Form struct containing the POSTed date from the
 	// form, then use the validation methods to check the content.
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	validateNewSnippet(form)
 
 	// If the form isn't valid, redisplay the template passing in the
-	// form.Form object as the data.
+	// form.Form object as the data. The content of an encrypted snippet is
+	// ciphertext by now, and its key has gone with the page, so there's no
+	// point sending it back.
 	if !form.Valid() {
-		a.render(w, r, "create.page.tmpl", &templateData{Form: form})
+		if form.Get("encrypted") != "" {
+			form.Del("content")
+		}
+		a.render(w, r, "create.page.tmpl", &templateData{
+			Form:      form,
+			Languages: highlight.Languages,
+		})
 		return
 	}
 
 	// Pass the data to the SnippetModel.Insert() receiving the ID of the new record back.
-	id, err := a.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	// The route is behind requireAuthenticatedUser, so the snippet is recorded as
+	// belonging to the current user.
+	id, err := a.snippets.Insert(a.authenticatedUser(r).ID, form.Get("title"), form.Get("content"), form.Get("language"), snippetExpiry(form), snippetOptions(form))
 	if err != nil {
 		a.serverError(w, err)
 		return
 	}
 
+	if tags := parseTags(form.Get("tags")); len(tags) > 0 {
+		if err := a.snippets.SetTags(id, tags); err != nil {
+			a.serverError(w, err)
+			return
+		}
+	}
+
 	// Use the Put() method to add a string value ("Your snippet was saved
 	// successfully!") and the corresponding key ("flash") to the session
 	// data. Note that if there's no existing session for the current user
@@ -95,17 +174,650 @@ func (a *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// will automatically be created by the session middleware.
 	a.session.Put(r, "flash", "Snippet successfully created!")
 
-	// Redirect the user to the relevant page for the snippet.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+	// Redirect the user to the relevant page for the snippet. That page is
+	// under the snippet's slug, so we load the new snippet back first.
+	s, err := a.snippets.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+// The redirectSnippet handler serves the old /snippet/:id URLs, and any page
+// under them like /snippet/:id/raw, by permanently redirecting to the same
+// page under the snippet's slug. Only public snippets are redirected: the
+// others respond with 404 Not Found, so counting up through the ids can't be
+// used to find the slugs of unlisted and private snippets.
+func (a *application) redirectSnippet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		a.notFound(w)
+		return
+	}
+
+	s, err := a.snippets.Get(id)
+	if err == models.ErrNoRecord {
+		a.notFound(w)
+		return
+	} else if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if s.Visibility != models.VisibilityPublic {
+		a.notFound(w)
+		return
+	}
+
+	// Keep whatever followed the id in the path, and the query string
+	// without the parameters which pat adds for the route's wildcards.
+	rest := strings.TrimPrefix(r.URL.Path, "/snippet/"+r.URL.Query().Get(":id"))
+	q := r.URL.Query()
+	for k := range q {
+		if strings.HasPrefix(k, ":") {
+			q.Del(k)
+		}
+	}
+
+	u := snippetURL(s) + rest
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	http.Redirect(w, r, u, http.StatusMovedPermanently)
+}
+
+// The unlockSnippet handler checks the password posted from the unlock form.
+// If it's right, the snippet is remembered as unlocked in the session, for
+// this snippet only, and we go back to its page.
+func (a *application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	err := a.snippets.Unlock(s.ID, form.Get("password"))
+	if err == models.ErrInvalidCredentials {
+		form.Errors.Add("generic", "The password is incorrect")
+		a.render(w, r, "unlock.page.tmpl", &templateData{
+			Form:    form,
+			Snippet: s,
+		})
+		return
+	} else if err != nil && err != models.ErrNoRecord {
+		// ErrNoRecord means the snippet has no password, so there's
+		// nothing to unlock.
+		a.serverError(w, err)
+		return
+	}
+
+	a.session.Put(r, unlockedKey(s), true)
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
 }
 
 func (a *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
 	a.render(w, r, "create.page.tmpl", &templateData{
 		// Pass a new empty forms.Form object to the template.
+		Form:      forms.New(nil),
+		Languages: highlight.Languages,
+	})
+}
+
+func (a *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// The server can't read an encrypted snippet, so it can't offer to
+	// edit one either.
+	if s.Encrypted {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Pre-fill the form with the snippet's current title and content.
+	form := forms.New(url.Values{})
+	form.Set("title", s.Title)
+	form.Set("content", s.Content)
+
+	a.render(w, r, "edit.page.tmpl", &templateData{
+		Form:    form,
+		Snippet: s,
+	})
+}
+
+func (a *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if s.Encrypted {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateSnippetEdit(form)
+
+	if !form.Valid() {
+		a.render(w, r, "edit.page.tmpl", &templateData{
+			Form:    form,
+			Snippet: s,
+		})
+		return
+	}
+
+	if err := a.snippets.Update(s.ID, a.authenticatedUser(r).ID, form.Get("title"), form.Get("content")); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.session.Put(r, "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+func (a *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.snippets.Delete(s.ID)
+	if err == models.ErrNoRecord {
+		a.notFound(w)
+		return
+	} else if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.session.Put(r, "flash", "Snippet successfully deleted!")
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+// The extendSnippet handler changes when one of the user's snippets expires.
+// Extending a live snippet adds to the time it was going to expire, and
+// renewing one which has already expired starts again from now. The form
+// is a select, so an invalid choice can only come from a tampered request.
+func (a *application) extendSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.renewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("extend")
+	if form.Get("extend") != "never" {
+		form.ValidDuration("extend", maxExpiry)
+	}
+	if !form.Valid() {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var expires time.Time
+	if form.Get("extend") != "never" {
+		from := time.Now()
+		if s.Expires.After(from) {
+			from = s.Expires
+		}
+		expires, _ = forms.AddDuration(from, form.Get("extend"))
+		if time.Until(expires) > maxExpiry {
+			a.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := a.snippets.Extend(s.ID, expires)
+	if err == models.ErrNoRecord {
+		a.notFound(w)
+		return
+	} else if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.session.Put(r, "flash", "Snippet expiry successfully extended!")
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+// The forkSnippet handler copies a snippet the user can see into a new one
+// of their own, which they can then edit. The fork keeps the original's
+// title, content, language, tags, visibility and expiry, and remembers which
+// snippet it was forked from. It doesn't keep the password: anyone who could
+// fork the snippet has already unlocked it. Encrypted snippets can't be
+// forked, because we don't have their content, and neither can
+// burn-after-reading ones, which are only meant to be read once.
+func (a *application) forkSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.snippetFromURL(w, r)
+	if !ok || !a.requireUnlocked(w, r, s) {
+		return
+	}
+
+	if s.Encrypted || s.BurnAfterReading {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := a.authenticatedUser(r)
+	id, err := a.snippets.Insert(user.ID, s.Title, s.Content, s.Language, s.Expires, models.SnippetOptions{
+		Visibility: s.Visibility,
+		ForkedFrom: s.ID,
+	})
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err := a.snippets.SetTags(id, s.Tags); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	fork, err := a.snippets.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.session.Put(r, "flash", "Snippet successfully forked!")
+	http.Redirect(w, r, snippetURL(fork), http.StatusSeeOther)
+}
+
+// The starSnippet and unstarSnippet handlers add and remove the user's star
+// on a snippet they can see, and go back to its page. Burn-after-reading
+// snippets can't be starred, because there would be nothing to come back
+// to.
+func (a *application) starSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.snippetFromURL(w, r)
+	if !ok || !a.requireUnlocked(w, r, s) {
+		return
+	}
+
+	if s.BurnAfterReading {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := a.snippets.Star(a.authenticatedUser(r).ID, s.ID); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+func (a *application) unstarSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.snippets.Unstar(a.authenticatedUser(r).ID, s.ID); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+}
+
+// The rawSnippet handler sends just the content of a snippet as plain text,
+// so that it can be piped straight from curl into a shell or file.
+// curl http://localhost:8080/s/$SLUG/raw
+func (a *application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !a.requireUnlocked(w, r, s) {
+		return
+	}
+	if _, ok := a.readSnippet(w, r, s); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(s.Content))
+}
+
+// The downloadSnippet handler works like rawSnippet, but asks the browser to
+// save the content as a file named after the snippet's title and language.
+func (a *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !a.requireUnlocked(w, r, s) {
+		return
+	}
+	if _, ok := a.readSnippet(w, r, s); !ok {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": snippetFilename(s),
+	})
+
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(s.Content))
+}
+
+// The snippetHistory handler lists every revision of a snippet, newest first.
+func (a *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !a.requireUnlocked(w, r, s) {
+		return
+	}
+
+	// A burn-after-reading snippet's revisions would give its content away
+	// without reading it, so only its owner may see them.
+	if s.BurnAfterReading && !isOwner(a.authenticatedUser(r), s) {
+		a.notFound(w)
+		return
+	}
+
+	revisions, err := a.snippets.Revisions(s.ID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.render(w, r, "history.page.tmpl", &templateData{
+		Snippet:   s,
+		Revisions: revisions,
+	})
+}
+
+// The snippetDiff handler shows a unified diff of the content of two
+// revisions of a snippet.
+func (a *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !a.requireUnlocked(w, r, s) {
+		return
+	}
+
+	// A burn-after-reading snippet's revisions would give its content away
+	// without reading it, so only its owner may see them.
+	if s.BurnAfterReading && !isOwner(a.authenticatedUser(r), s) {
+		a.notFound(w)
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get(":from"))
+	if err != nil || from < 1 {
+		a.notFound(w)
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get(":to"))
+	if err != nil || to < 1 {
+		a.notFound(w)
+		return
+	}
+
+	revisions := make([]*models.Revision, 2)
+	for i, number := range []int{from, to} {
+		revisions[i], err = a.snippets.Revision(s.ID, number)
+		if err == models.ErrNoRecord {
+			a.notFound(w)
+			return
+		} else if err != nil {
+			a.serverError(w, err)
+			return
+		}
+	}
+
+	a.render(w, r, "diff.page.tmpl", &templateData{
+		Snippet:   s,
+		Revisions: revisions,
+		Diff:      diff.Unified(revisions[0].Content, revisions[1].Content, 3),
+	})
+}
+
+// The userSnippets handler lists all of the authenticated user's own snippets,
+// including the ones which have expired.
+func (a *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	s, err := a.snippets.ByUser(a.authenticatedUser(r).ID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.render(w, r, "mysnippets.page.tmpl", &templateData{
+		Snippets: s,
+	})
+}
+
+// The userStarred handler lists the snippets the user has starred.
+func (a *application) userStarred(w http.ResponseWriter, r *http.Request) {
+	s, err := a.snippets.StarredBy(a.authenticatedUser(r).ID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.render(w, r, "starred.page.tmpl", &templateData{
+		Snippets: s,
+	})
+}
+
+// The number of results on each page of the search page.
+const searchPerPage = 20
+
+// The search handler shows the search form and, once a query has been given,
+// the matching snippets. The form is submitted with GET, so that searches
+// can be bookmarked and shared.
+func (a *application) search(w http.ResponseWriter, r *http.Request) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.URL.Query())
+	validateSearch(form)
+
+	td := &templateData{
+		Form:      form,
+		Languages: highlight.Languages,
+	}
+
+	if form.Get("q") == "" || !form.Valid() {
+		a.render(w, r, "search.page.tmpl", td)
+		return
+	}
+
+	// As with the API's list endpoint, we ask for one more result than we
+	// show, to find out whether there's a next page.
+	s, err := a.snippets.Search(searchQuery(form), searchPerPage+1, (page-1)*searchPerPage)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if len(s) > searchPerPage {
+		s = s[:searchPerPage]
+		td.NextURL = pageURL(r, page+1)
+	}
+	if page > 1 {
+		td.PrevURL = pageURL(r, page-1)
+	}
+	td.Snippets = s
+
+	a.render(w, r, "search.page.tmpl", td)
+}
+
+// The number of snippets on each page of the browse page.
+const browsePerPage = 20
+
+// The browse page lists every snippet which hasn't expired, a page at a time,
+// sorted by the sort query parameter. The previous and next links carry a
+// cursor instead of a page number, so that new snippets don't shift the
+// pages while someone is reading them.
+func (a *application) browse(w http.ResponseWriter, r *http.Request) {
+	q, err := listQuery(r, browsePerPage)
+	if err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	p, err := a.listSnippets(q)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.renderBrowse(w, r, q, p)
+}
+
+// The tag page is the browse page for the snippets with one tag.
+func (a *application) tag(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get(":tag")
+	if !tagRX.MatchString(tag) {
+		a.notFound(w)
+		return
+	}
+
+	q, err := listQuery(r, browsePerPage)
+	if err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+	q.Tag = tag
+
+	p, err := a.listSnippets(q)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.renderBrowse(w, r, q, p)
+}
+
+// renderBrowse renders a page of snippets listed by browse or tag.
+func (a *application) renderBrowse(w http.ResponseWriter, r *http.Request, q models.ListQuery, p *snippetPage) {
+	td := &templateData{
+		Snippets: p.Snippets,
+		Sort:     q.Sort,
+		Tag:      q.Tag,
+	}
+	if p.Prev != "" {
+		td.PrevURL = cursorURL(r, "before", p.Prev)
+	}
+	if p.Next != "" {
+		td.NextURL = cursorURL(r, "after", p.Next)
+	}
+
+	a.render(w, r, "browse.page.tmpl", td)
+}
+
+// The settings page lists the user's API tokens, with a form to create a new
+// one and buttons to revoke the existing ones.
+func (a *application) settings(w http.ResponseWriter, r *http.Request) {
+	a.renderSettings(w, r, &templateData{
 		Form: forms.New(nil),
 	})
 }
 
+func (a *application) createToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// The expiry is a number of days, and leaving it blank means the token
+	// never expires.
+	form := forms.New(r.PostForm)
+	form.Required("name")
+	form.MaxLength("name", 100)
+	form.PermittedValues("expires", "30", "90", "365")
+
+	if !form.Valid() {
+		a.renderSettings(w, r, &templateData{Form: form})
+		return
+	}
+
+	var expires time.Time
+	if days, err := strconv.Atoi(form.Get("expires")); err == nil {
+		expires = time.Now().AddDate(0, 0, days)
+	}
+
+	token, err := a.tokens.Insert(a.authenticatedUser(r).ID, form.Get("name"), expires)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	// We only keep a hash of the token, so this is the one and only time it
+	// can be shown. Rather than redirecting, we render the settings page
+	// straight away with the token on it.
+	a.renderSettings(w, r, &templateData{
+		Form:     forms.New(nil),
+		NewToken: token,
+	})
+}
+
+func (a *application) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		a.notFound(w)
+		return
+	}
+
+	// Delete only matches tokens belonging to the current user, so there's
+	// no need for a separate ownership check.
+	err = a.tokens.Delete(id, a.authenticatedUser(r).ID)
+	if err == models.ErrNoRecord {
+		a.notFound(w)
+		return
+	} else if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.session.Put(r, "flash", "API token revoked.")
+	http.Redirect(w, r, "/user/settings", http.StatusSeeOther)
+}
+
+// renderSettings adds the user's API tokens to td and renders the settings
+// page.
+func (a *application) renderSettings(w http.ResponseWriter, r *http.Request, td *templateData) {
+	tokens, err := a.tokens.ByUser(a.authenticatedUser(r).ID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	td.Tokens = tokens
+	a.render(w, r, "settings.page.tmpl", td)
+}
+
 func (a *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
 	a.render(w, r, "signup.page.tmpl", &templateData{
 		Form: forms.New(nil),
@@ -0,0 +1,1464 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestShowSnippet(t *testing.T) {
+	// Create a new instance of our application struct which uses the
+	// in-memory stores, and seed it with a snippet.
+	app := newTestApplication(t)
+	id, err := app.snippets.Insert(1, "An old silent pond", "An old silent pond...", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := snippetPath(t, app, id)
+
+	// Establish a new test server for running end-to-end tests.
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid slug", path, http.StatusOK, []byte("An old silent pond...")},
+		{"Non-existent slug", "/s/AAAAAAAAAAAA", http.StatusNotFound, nil},
+		{"Empty slug", "/s/", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRedirectSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	id, err := app.snippets.Insert(1, "An old silent pond", "An old silent pond...", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := snippetPath(t, app, id)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Valid ID", "/snippet/1", http.StatusMovedPermanently, path},
+		{"Page under the ID", "/snippet/1/raw", http.StatusMovedPermanently, path + "/raw"},
+		{"Query string", "/snippet/1?view=source", http.StatusMovedPermanently, path + "?view=source"},
+		{"Non-existent ID", "/snippet/2", http.StatusNotFound, ""},
+		{"Negative ID", "/snippet/-1", http.StatusNotFound, ""},
+		{"Decimal ID", "/snippet/1.23", http.StatusNotFound, ""},
+		{"String ID", "/snippet/foo", http.StatusNotFound, ""},
+		{"Empty ID", "/snippet/", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+}
+
+func TestShowMarkdownSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	content := "# Runbook\n\n<script>alert(1)</script>"
+	id, err := app.snippets.Insert(1, "Runbook", content, "markdown", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := snippetPath(t, app, id)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantBody    []byte
+		wantNotBody []byte
+	}{
+		{"Rendered", path, []byte("<h1>Runbook</h1>"), []byte("<script>alert(1)")},
+		{"Source", path + "?view=source", []byte("# Runbook"), []byte("<h1>Runbook</h1>")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			if bytes.Contains(body, tt.wantNotBody) {
+				t.Errorf("want body not to contain %q", tt.wantNotBody)
+			}
+		})
+	}
+}
+
+func TestRawAndDownloadSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	id, err := app.snippets.Insert(1, "Hello, World!", "package main\n", "go", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := snippetPath(t, app, id)
+	if _, err := app.snippets.Insert(1, "Expired", "Gone", "", time.Now().Add(-time.Minute), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The expired snippet can't be fetched with Get, but it's still listed
+	// among its owner's snippets.
+	all, err := app.snippets.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := snippetURL(all[0])
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantBody        []byte
+		wantDisposition string
+	}{
+		{"Raw", path + "/raw", http.StatusOK, []byte("package main\n"), ""},
+		{"Download", path + "/download", http.StatusOK, []byte("package main\n"), "attachment; filename=hello-world.go"},
+		{"Expired raw", expired + "/raw", http.StatusNotFound, nil, ""},
+		{"Expired download", expired + "/download", http.StatusNotFound, nil, ""},
+		{"Non-existent slug", "/s/AAAAAAAAAAAA/raw", http.StatusNotFound, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if ct := header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+				t.Errorf("want text/plain content type; got %q", ct)
+			}
+			if !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("want body %q; got %q", tt.wantBody, body)
+			}
+			if cd := header.Get("Content-Disposition"); cd != tt.wantDisposition {
+				t.Errorf("want Content-Disposition %q; got %q", tt.wantDisposition, cd)
+			}
+		})
+	}
+}
+
+func TestSignupUser(t *testing.T) {
+	app := newTestApplication(t)
+	if err := app.users.Insert("Alice", "dupe@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Make a GET /user/signup request and then extract the CSRF token from the
+	// response body.
+	_, _, body := ts.get(t, "/user/signup")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		csrfToken    string
+		wantCode     int
+		wantBody     []byte
+	}{
+		{"Valid submission", "Bob", "bob@example.com", "validPa$$word", csrfToken, http.StatusSeeOther, nil},
+		{"Empty name", "", "bob@example.com", "validPa$$word", csrfToken, http.StatusOK, []byte("This field cannot be blank")},
+		{"Invalid email", "Bob", "bob@example.", "validPa$$word", csrfToken, http.StatusOK, []byte("This field is invalid")},
+		{"Short password", "Bob", "bob@example.com", "pa$$word", csrfToken, http.StatusOK, []byte("This field is too short")},
+		{"Duplicate email", "Bob", "dupe@example.com", "validPa$$word", csrfToken, http.StatusOK, []byte("Address is already in use")},
+		{"Invalid CSRF Token", "", "", "", "wrongToken", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCreateSnippetLanguage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		language string
+		wantCode int
+	}{
+		{"Valid language", "go", http.StatusSeeOther},
+		{"Detect automatically", "", http.StatusSeeOther},
+		{"Unsupported language", "klingon", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Hello")
+			form.Add("content", "package main")
+			form.Add("language", tt.language)
+			form.Add("expires", "1w")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code == http.StatusOK && !bytes.Contains(body, []byte("This field is invalid")) {
+				t.Errorf("want body to contain a validation error")
+			}
+		})
+	}
+
+	// The snippet created with a language is shown highlighted.
+	_, _, body = ts.get(t, snippetPath(t, app, 1))
+	if !bytes.Contains(body, []byte(`<span class="kn">package</span>`)) {
+		t.Errorf("want highlighted content in body")
+	}
+}
+
+func TestCreateSnippetExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	aliceID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	now := time.Now().UTC()
+	nextYear := now.AddDate(1, 0, 0).Truncate(time.Minute)
+
+	tests := []struct {
+		name        string
+		expires     string
+		expiresAt   string
+		wantCode    int
+		wantExpires time.Time
+		wantError   string
+	}{
+		{"Ten minutes", "10m", "", http.StatusSeeOther, now.Add(10 * time.Minute), ""},
+		{"One month", "1mo", "", http.StatusSeeOther, now.AddDate(0, 1, 0), ""},
+		{"Never", "never", "", http.StatusSeeOther, time.Time{}, ""},
+		{"Custom", "custom", nextYear.Format("2006-01-02T15:04"), http.StatusSeeOther, nextYear, ""},
+		{"Custom in the past", "custom", now.Add(-time.Hour).Format("2006-01-02T15:04"), http.StatusOK, time.Time{}, "This field must be in the future"},
+		{"Custom too far ahead", "custom", "2999-01-01T00:00", http.StatusOK, time.Time{}, "This field is too far in the future"},
+		{"Custom without a time", "custom", "", http.StatusOK, time.Time{}, "This field cannot be blank"},
+		{"Invalid", "forever", "", http.StatusOK, time.Time{}, "This field is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Hello")
+			form.Add("content", "World")
+			form.Add("expires", tt.expires)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code == http.StatusOK {
+				if !bytes.Contains(body, []byte(tt.wantError)) {
+					t.Errorf("want body to contain %q", tt.wantError)
+				}
+				return
+			}
+
+			// The newest of Alice's snippets is the one we just created.
+			snippets, err := app.snippets.ByUser(aliceID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := snippets[0].Expires
+			if tt.wantExpires.IsZero() != got.IsZero() || got.Sub(tt.wantExpires) > time.Minute || tt.wantExpires.Sub(got) > time.Minute {
+				t.Errorf("want the snippet to expire at %v; got %v", tt.wantExpires, got)
+			}
+		})
+	}
+}
+
+func TestCreateSnippetTags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		tags      string
+		wantCode  int
+		wantError []byte
+	}{
+		{"Valid tags", "K8s, sql bash,k8s", http.StatusSeeOther, nil},
+		{"Too many tags", "a b c d e f", http.StatusOK, []byte("Too many tags")},
+		{"Tag too long", strings.Repeat("x", 31), http.StatusOK, []byte("is too long")},
+		{"Invalid characters", "c#", http.StatusOK, []byte("may only contain")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Hello")
+			form.Add("content", "kubectl get pods")
+			form.Add("tags", tt.tags)
+			form.Add("expires", "1w")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantError != nil && !bytes.Contains(body, tt.wantError) {
+				t.Errorf("want body to contain %q", tt.wantError)
+			}
+		})
+	}
+
+	// The tags are lower-cased, deduplicated and shown in alphabetical order.
+	_, _, body = ts.get(t, snippetPath(t, app, 1))
+	want := []byte("<a href='/tags/bash'>bash</a><a href='/tags/k8s'>k8s</a><a href='/tags/sql'>sql</a>")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body to contain %q", want)
+	}
+}
+
+func TestTag(t *testing.T) {
+	app := newTestApplication(t)
+	for _, tags := range [][]string{{"k8s"}, {"k8s", "sql"}, {}} {
+		id, err := app.snippets.Insert(1, fmt.Sprintf("Tagged %v", tags), "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.snippets.SetTags(id, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The home page's tag cloud links to each tag with its count.
+	_, _, body := ts.get(t, "/")
+	want := []byte("<a href='/tags/k8s'>k8s <small>2</small></a>")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body to contain %q", want)
+	}
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []byte
+		wantNotBody []byte
+	}{
+		{"Tag", "/tags/sql", http.StatusOK, []byte("Tagged [k8s sql]"), []byte("Tagged [k8s]<")},
+		{"Sorted", "/tags/k8s?sort=oldest", http.StatusOK, []byte("Tagged [k8s]<"), []byte("Tagged []")},
+		{"Unused tag", "/tags/go", http.StatusOK, []byte("There's nothing to see here yet!"), nil},
+		{"Invalid tag", "/tags/C%23", http.StatusNotFound, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			if tt.wantNotBody != nil && bytes.Contains(body, tt.wantNotBody) {
+				t.Errorf("want body not to contain %q", tt.wantNotBody)
+			}
+		})
+	}
+}
+
+func TestUserSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Unauthenticated users are redirected to the login page.
+	code, header, _ := ts.get(t, "/user/snippets")
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Errorf("want redirect to /user/login; got %d %q", code, header.Get("Location"))
+	}
+
+	aliceID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+	if _, err := app.snippets.Insert(aliceID, "Alice's snippet", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.snippets.Insert(aliceID+1, "Bob's snippet", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, body := ts.get(t, "/user/snippets")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("Alice&#39;s snippet")) {
+		t.Errorf("want body to contain Alice's snippet")
+	}
+	if bytes.Contains(body, []byte("Bob&#39;s snippet")) {
+		t.Errorf("want body not to contain Bob's snippet")
+	}
+}
+
+func TestDeleteSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	aliceID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+	own, err := app.snippets.Insert(aliceID, "Alice's snippet", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := app.snippets.Insert(aliceID+1, "Bob's snippet", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownPath := snippetPath(t, app, own)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Someone else's snippet", snippetPath(t, app, other) + "/delete", http.StatusForbidden},
+		{"Own snippet", ownPath + "/delete", http.StatusSeeOther},
+		{"Already deleted", ownPath + "/delete", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	if _, err := app.snippets.Get(other); err != nil {
+		t.Errorf("want Bob's snippet to survive; got %v", err)
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	aliceID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+	own, err := app.snippets.Insert(aliceID, "Tpyo", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := app.snippets.Insert(aliceID+1, "Bob's snippet", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownPath, otherPath := snippetPath(t, app, own), snippetPath(t, app, other)
+
+	code, _, _ := ts.get(t, otherPath+"/edit")
+	if code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, code)
+	}
+
+	code, _, body := ts.get(t, ownPath+"/edit")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	form := url.Values{}
+	form.Add("title", "Typo")
+	form.Add("content", "Fixed content")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ = ts.postForm(t, otherPath+"/edit", form)
+	if code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, code)
+	}
+
+	code, _, _ = ts.postForm(t, ownPath+"/edit", form)
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	s, err := app.snippets.Get(own)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Typo" || s.Content != "Fixed content" {
+		t.Errorf("snippet was not updated: %+v", s)
+	}
+}
+
+func TestExtendSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	aliceID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+	soon, err := app.snippets.Insert(aliceID, "Nearly gone", "Content", "", time.Now().Add(time.Hour), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := app.snippets.Insert(aliceID, "Gone", "Content", "", time.Now().Add(-time.Hour), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := app.snippets.Insert(aliceID+1, "Bob's snippet", "Content", "", time.Now().Add(time.Hour), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The expired snippet can't be reached through Get(), so we find its
+	// slug among Alice's snippets.
+	snippets, err := app.snippets.ByUser(aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expiredPath string
+	for _, s := range snippets {
+		if s.ID == expired {
+			expiredPath = snippetURL(s)
+		}
+	}
+	soonPath := snippetPath(t, app, soon)
+
+	// The owner sees that the snippet is about to expire, and a form to
+	// extend it.
+	_, _, body := ts.get(t, soonPath)
+	if !bytes.Contains(body, []byte("expires soon")) || !bytes.Contains(body, []byte(soonPath+"/extend")) {
+		t.Errorf("want the expires soon badge and the extend form in body")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		extend      string
+		wantCode    int
+		wantExpires time.Time
+	}{
+		{"Extend", soonPath + "/extend", "1w", http.StatusSeeOther, time.Now().Add(time.Hour).AddDate(0, 0, 7)},
+		{"Renew", expiredPath + "/extend", "1w", http.StatusSeeOther, time.Now().AddDate(0, 0, 7)},
+		{"Invalid choice", soonPath + "/extend", "forever", http.StatusBadRequest, time.Time{}},
+		{"Too long", soonPath + "/extend", "20y", http.StatusBadRequest, time.Time{}},
+		{"Someone else's snippet", snippetPath(t, app, other) + "/extend", "1w", http.StatusForbidden, time.Time{}},
+		{"Missing snippet", "/s/no-such-slug/extend", "1w", http.StatusNotFound, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("extend", tt.extend)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusSeeOther {
+				return
+			}
+
+			// The snippet is live again, and expires when we asked.
+			loc := header.Get("Location")
+			if loc+"/extend" != tt.urlPath {
+				t.Errorf("want a redirect to the snippet; got %q", loc)
+			}
+			s, err := app.snippets.GetBySlug(strings.TrimPrefix(loc, "/s/"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Expires.Sub(tt.wantExpires) > time.Minute || tt.wantExpires.Sub(s.Expires) > time.Minute {
+				t.Errorf("want the snippet to expire at %v; got %v", tt.wantExpires, s.Expires)
+			}
+		})
+	}
+
+	// Extending it forever means it never expires.
+	form := url.Values{}
+	form.Add("extend", "never")
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, soonPath+"/extend", form); code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	s, err := app.snippets.Get(soon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Expires.IsZero() {
+		t.Errorf("want the snippet to never expire; got %v", s.Expires)
+	}
+}
+
+func TestForkSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	aliceID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+	bobID := aliceID + 1
+	expires := time.Now().AddDate(0, 0, 7)
+
+	original, err := app.snippets.Insert(bobID, "Bob's snippet", "echo hello", "bash", expires, models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.snippets.SetTags(original, []string{"shell"}); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := app.snippets.Insert(bobID, "Secret", "ciphertext", "", expires, models.SnippetOptions{Visibility: models.VisibilityUnlisted, Encrypted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	burn, err := app.snippets.Insert(bobID, "Once", "Content", "", expires, models.SnippetOptions{Visibility: models.VisibilityUnlisted, BurnAfterReading: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := app.snippets.Insert(bobID, "Private", "Content", "", expires, models.SnippetOptions{Visibility: models.VisibilityPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalPath := snippetPath(t, app, original)
+	_, _, body := ts.get(t, originalPath)
+	if !bytes.Contains(body, []byte(originalPath+"/fork")) {
+		t.Errorf("want the fork form in body")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Encrypted snippet", snippetPath(t, app, encrypted) + "/fork", http.StatusBadRequest},
+		{"Burn after reading snippet", snippetPath(t, app, burn) + "/fork", http.StatusBadRequest},
+		{"Someone else's private snippet", snippetPath(t, app, private) + "/fork", http.StatusNotFound},
+		{"Missing snippet", "/s/no-such-slug/fork", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, header, _ := ts.postForm(t, originalPath+"/fork", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// The fork belongs to Alice, and is a copy of Bob's snippet which
+	// remembers where it came from.
+	loc := header.Get("Location")
+	fork, err := app.snippets.GetBySlug(strings.TrimPrefix(loc, "/s/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ID == original || fork.UserID != aliceID || fork.ForkedFrom != original {
+		t.Errorf("want a new snippet owned by %d and forked from %d; got %+v", aliceID, original, fork)
+	}
+	if fork.Content != "echo hello" || fork.Language != "bash" || len(fork.Tags) != 1 || fork.Tags[0] != "shell" {
+		t.Errorf("want a copy of the original; got %+v", fork)
+	}
+
+	_, _, body = ts.get(t, loc)
+	if !bytes.Contains(body, []byte(fmt.Sprintf("Forked from <a href='%s'>#%d</a>", originalPath, original))) {
+		t.Errorf("want the fork to link to the original in body")
+	}
+
+	_, _, body = ts.get(t, originalPath)
+	if !bytes.Contains(body, []byte("1 fork")) {
+		t.Errorf("want the original to show its forks count in body")
+	}
+}
+
+func TestStarSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Unauthenticated users are redirected to the login page.
+	code, header, _ := ts.get(t, "/user/starred")
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Errorf("want redirect to /user/login; got %d %q", code, header.Get("Location"))
+	}
+
+	aliceID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+	bobID := aliceID + 1
+	expires := time.Now().AddDate(0, 0, 7)
+
+	starred, err := app.snippets.Insert(bobID, "Bob's snippet", "Content", "", expires, models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.snippets.Insert(bobID, "Unstarred snippet", "Content", "", expires, models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	burn, err := app.snippets.Insert(bobID, "Once", "Content", "", expires, models.SnippetOptions{Visibility: models.VisibilityUnlisted, BurnAfterReading: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := snippetPath(t, app, starred)
+	_, _, body := ts.get(t, path)
+	if !bytes.Contains(body, []byte(path+"/star")) {
+		t.Errorf("want the star form in body")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	// A POST without the CSRF token is rejected.
+	if code, _, _ := ts.postForm(t, path+"/star", url.Values{}); code != http.StatusBadRequest {
+		t.Errorf("want %d without a CSRF token; got %d", http.StatusBadRequest, code)
+	}
+	if code, _, _ := ts.postForm(t, snippetPath(t, app, burn)+"/star", form); code != http.StatusBadRequest {
+		t.Errorf("want %d for a burn after reading snippet; got %d", http.StatusBadRequest, code)
+	}
+
+	// Starring twice only counts once.
+	for i := 0; i < 2; i++ {
+		code, header, _ := ts.postForm(t, path+"/star", form)
+		if code != http.StatusSeeOther || header.Get("Location") != path {
+			t.Fatalf("want redirect to %s; got %d %q", path, code, header.Get("Location"))
+		}
+	}
+
+	_, _, body = ts.get(t, path)
+	if !bytes.Contains(body, []byte("&#9733; 1")) || !bytes.Contains(body, []byte(path+"/unstar")) {
+		t.Errorf("want the star count and the unstar form in body")
+	}
+
+	_, _, body = ts.get(t, "/")
+	if !bytes.Contains(body, []byte("Most Starred This Week")) {
+		t.Errorf("want the most starred section in body")
+	}
+
+	_, _, body = ts.get(t, "/user/starred")
+	if !bytes.Contains(body, []byte("Bob&#39;s snippet")) || bytes.Contains(body, []byte("Unstarred snippet")) {
+		t.Errorf("want only the starred snippet in body")
+	}
+
+	if code, _, _ := ts.postForm(t, path+"/unstar", form); code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	_, _, body = ts.get(t, "/user/starred")
+	if bytes.Contains(body, []byte("Bob&#39;s snippet")) {
+		t.Errorf("want no starred snippets in body")
+	}
+}
+
+func TestSnippetDiff(t *testing.T) {
+	app := newTestApplication(t)
+	id, err := app.snippets.Insert(1, "Title", "one\ntwo\nthree\n", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.snippets.Update(id, 1, "Title", "one\n2\nthree\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := snippetPath(t, app, id)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, path+"/history")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte(path+"/diff/1/2")) {
+		t.Errorf("want history to link to the diff between revisions 1 and 2")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid revisions", path + "/diff/1/2", http.StatusOK, []byte("<span class='del'>-two</span>\n<span class='ins'>&#43;2</span>")},
+		{"Reversed revisions", path + "/diff/2/1", http.StatusOK, []byte("<span class='del'>-2</span>")},
+		{"Missing revision", path + "/diff/1/3", http.StatusNotFound, nil},
+		{"Invalid revision", path + "/diff/1/foo", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSettingsTokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Unauthenticated users are redirected to the login page.
+	code, header, _ := ts.get(t, "/user/settings")
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Errorf("want redirect to /user/login; got %d %q", code, header.Get("Location"))
+	}
+
+	aliceID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/user/settings")
+	csrfToken := extractCSRFToken(t, body)
+
+	// An invalid form is redisplayed with its errors.
+	form := url.Values{}
+	form.Add("name", "")
+	form.Add("expires", "2")
+	form.Add("csrf_token", csrfToken)
+	code, _, body = ts.postForm(t, "/user/settings/tokens", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("This field cannot be blank")) {
+		t.Errorf("want the form redisplayed with errors; got %d", code)
+	}
+
+	// A valid form shows the new token, once, and the token works.
+	form.Set("name", "Deploy script")
+	form.Set("expires", "30")
+	code, _, body = ts.postForm(t, "/user/settings/tokens", form)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	m := regexp.MustCompile(`<code>([A-Za-z0-9_-]{43})</code>`).FindSubmatch(body)
+	if m == nil {
+		t.Fatal("want the new token in the body")
+	}
+	if id, err := app.tokens.Authenticate(string(m[1])); err != nil || id != aliceID {
+		t.Errorf("want token to authenticate user %d; got %d, %v", aliceID, id, err)
+	}
+
+	_, _, body = ts.get(t, "/user/settings")
+	if !bytes.Contains(body, []byte("Deploy script")) || bytes.Contains(body, m[1]) {
+		t.Errorf("want the token listed by name but not shown again")
+	}
+
+	// Tokens can be revoked, but only by their owner.
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, "/user/settings/tokens/2/revoke", form); code != http.StatusNotFound {
+		t.Errorf("revoking a missing token: want %d; got %d", http.StatusNotFound, code)
+	}
+	if code, _, _ := ts.postForm(t, "/user/settings/tokens/1/revoke", form); code != http.StatusSeeOther {
+		t.Errorf("revoking a token: want %d; got %d", http.StatusSeeOther, code)
+	}
+	if _, err := app.tokens.Authenticate(string(m[1])); err != models.ErrInvalidCredentials {
+		t.Errorf("want a revoked token to be rejected; got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	if err := app.users.Insert("Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.snippets.Insert(1, "Reload nginx", "sudo systemctl reload nginx", "bash", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= searchPerPage; i++ {
+		if _, err := app.snippets.Insert(2, "Docker", "docker ps", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []byte
+		wantNotBody []byte
+	}{
+		{"No query", "/search", http.StatusOK, []byte("<form action='/search'"), []byte("No snippets matched")},
+		{"Highlighted match", "/search?q=nginx", http.StatusOK, []byte("Reload <mark>nginx</mark>"), nil},
+		{"Filtered by language", "/search?q=nginx&language=go", http.StatusOK, []byte("No snippets matched"), nil},
+		{"Filtered by author", "/search?q=nginx&author=alice", http.StatusOK, []byte("<mark>nginx</mark>"), nil},
+		{"Invalid language", "/search?q=nginx&language=klingon", http.StatusOK, []byte("This field is invalid"), []byte("<mark>")},
+		{"First page", "/search?q=docker", http.StatusOK, []byte("/search?page=2&amp;q=docker"), []byte("Previous")},
+		{"Last page", "/search?q=docker&page=2", http.StatusOK, []byte("/search?page=1&amp;q=docker"), []byte("Next")},
+		{"Invalid page", "/search?q=docker&page=x", http.StatusBadRequest, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			if tt.wantNotBody != nil && bytes.Contains(body, tt.wantNotBody) {
+				t.Errorf("want body not to contain %q", tt.wantNotBody)
+			}
+		})
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	app := newTestApplication(t)
+	for i := 0; i <= browsePerPage; i++ {
+		if _, err := app.snippets.Insert(1, "Title", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The first page should only link to the next one, and following that
+	// link should lead to a page which only links back.
+	code, _, body := ts.get(t, "/snippets")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if bytes.Contains(body, []byte("Previous")) {
+		t.Errorf("want no previous link on the first page")
+	}
+
+	next := regexp.MustCompile(`href='(/snippets\?after=[^']+)'`).FindSubmatch(body)
+	if next == nil {
+		t.Fatal("want a next link on the first page")
+	}
+
+	code, _, body = ts.get(t, html.UnescapeString(string(next[1])))
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("<a href='"+snippetPath(t, app, 1)+"'>")) {
+		t.Errorf("want the oldest snippet on the last page")
+	}
+	if !bytes.Contains(body, []byte("/snippets?before=")) || bytes.Contains(body, []byte("Next")) {
+		t.Errorf("want only a previous link on the last page")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Oldest first", "/snippets?sort=oldest", http.StatusOK},
+		{"Expiring soonest", "/snippets?sort=expiring", http.StatusOK},
+		{"Invalid sort", "/snippets?sort=random", http.StatusBadRequest},
+		{"Invalid cursor", "/snippets?after=xyz", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestSnippetVisibility(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Alice, Bob and an anonymous visitor each get their own client.
+	alice := newTestServer(t, app.routes())
+	defer alice.Close()
+	aliceID := alice.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	bob := newTestServer(t, app.routes())
+	defer bob.Close()
+	bob.signupAndLogin(t, app, "Bob", "bob@example.com")
+
+	anon := newTestServer(t, app.routes())
+	defer anon.Close()
+
+	snippets := map[string]*models.Snippet{}
+	for _, v := range []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate} {
+		id, err := app.snippets.Insert(aliceID, "A "+v+" snippet", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{Visibility: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snippets[v], err = app.snippets.Get(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	public, unlisted, private := snippets[models.VisibilityPublic], snippets[models.VisibilityUnlisted], snippets[models.VisibilityPrivate]
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		urlPath  string
+		wantCode int
+	}{
+		{"Public by id", anon, fmt.Sprintf("/snippet/%d", public.ID), http.StatusMovedPermanently},
+		{"Public by slug", anon, "/s/" + public.Slug, http.StatusOK},
+		{"Unlisted by id", anon, fmt.Sprintf("/snippet/%d", unlisted.ID), http.StatusNotFound},
+		{"Unlisted raw by id", anon, fmt.Sprintf("/snippet/%d/raw", unlisted.ID), http.StatusNotFound},
+		{"Unlisted by slug", anon, "/s/" + unlisted.Slug, http.StatusOK},
+		{"Unlisted raw by slug", anon, "/s/" + unlisted.Slug + "/raw", http.StatusOK},
+		{"Unlisted history by slug", anon, "/s/" + unlisted.Slug + "/history", http.StatusOK},
+		{"Unlisted by id for its owner", alice, fmt.Sprintf("/snippet/%d", unlisted.ID), http.StatusNotFound},
+		{"Private by id for its owner", alice, fmt.Sprintf("/snippet/%d", private.ID), http.StatusNotFound},
+		{"Private by slug", anon, "/s/" + private.Slug, http.StatusNotFound},
+		{"Private by slug for someone else", bob, "/s/" + private.Slug, http.StatusNotFound},
+		{"Private download for someone else", bob, "/s/" + private.Slug + "/download", http.StatusNotFound},
+		{"Private edit for someone else", bob, "/s/" + private.Slug + "/edit", http.StatusNotFound},
+		{"Private by slug for its owner", alice, "/s/" + private.Slug, http.StatusOK},
+		{"Private raw for its owner", alice, "/s/" + private.Slug + "/raw", http.StatusOK},
+		{"Unknown slug", anon, "/s/nope", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := tt.ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	// Only the public snippet is listed on the home page, and the links on
+	// an unlisted snippet's page keep to its slug.
+	_, _, body := anon.get(t, "/")
+	if bytes.Contains(body, []byte("A unlisted snippet")) || bytes.Contains(body, []byte("A private snippet")) {
+		t.Errorf("want only public snippets on the home page")
+	}
+
+	_, _, body = anon.get(t, "/s/"+unlisted.Slug)
+	want := []byte("<a href='/s/" + unlisted.Slug + "/raw'>Raw</a>")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body to contain %q", want)
+	}
+
+	// Creating an unlisted snippet redirects to its slug.
+	_, _, body = alice.get(t, "/snippet/create")
+	form := url.Values{}
+	form.Add("title", "Shared with the team")
+	form.Add("content", "Content")
+	form.Add("visibility", models.VisibilityUnlisted)
+	form.Add("expires", "1w")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := alice.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther || !strings.HasPrefix(header.Get("Location"), "/s/") {
+		t.Errorf("want a redirect to /s/:slug; got %d %q", code, header.Get("Location"))
+	}
+}
+
+func TestBurnAfterReading(t *testing.T) {
+	app := newTestApplication(t)
+
+	alice := newTestServer(t, app.routes())
+	defer alice.Close()
+	alice.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	bob := newTestServer(t, app.routes())
+	defer bob.Close()
+
+	// Burning after reading turns a public snippet into an unlisted one.
+	_, _, body := alice.get(t, "/snippet/create")
+	form := url.Values{}
+	form.Add("title", "Database password")
+	form.Add("content", "hunter2")
+	form.Add("burn", "true")
+	form.Add("expires", "1w")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := alice.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	path := header.Get("Location")
+
+	s, err := app.snippets.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.BurnAfterReading || s.Visibility != models.VisibilityUnlisted {
+		t.Errorf("want an unlisted burn-after-reading snippet; got %+v", s)
+	}
+
+	// The owner can look at it as often as they like without reading it.
+	for i := 0; i < 2; i++ {
+		code, _, body = alice.get(t, path)
+		if code != http.StatusOK || !bytes.Contains(body, []byte("not read yet")) {
+			t.Fatalf("want the owner to see an unread snippet; got %d", code)
+		}
+	}
+
+	// Its revisions aren't shown to anyone else, even before it's read.
+	if code, _, _ = bob.get(t, path+"/history"); code != http.StatusNotFound {
+		t.Errorf("want %d for the history; got %d", http.StatusNotFound, code)
+	}
+
+	code, header, body = bob.get(t, path)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("hunter2")) {
+		t.Fatalf("want the first view to show the snippet; got %d", code)
+	}
+	if cc := header.Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("want Cache-Control no-store; got %q", cc)
+	}
+
+	for _, urlPath := range []string{path, path + "/raw", path + "/download"} {
+		if code, _, _ := bob.get(t, urlPath); code != http.StatusGone {
+			t.Errorf("%s: want %d; got %d", urlPath, http.StatusGone, code)
+		}
+	}
+
+	_, _, body = alice.get(t, path)
+	if !bytes.Contains(body, []byte("Read: ")) {
+		t.Errorf("want the owner to see when the snippet was read")
+	}
+}
+
+func TestBurnAfterReadingHead(t *testing.T) {
+	app := newTestApplication(t)
+	id, err := app.snippets.Insert(1, "Database password", "hunter2", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{Visibility: models.VisibilityUnlisted, BurnAfterReading: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := snippetPath(t, app, id)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// HEAD requests, like the ones link checkers and link previews make,
+	// don't read the snippet.
+	for _, urlPath := range []string{path, path + "/raw", path + "/download"} {
+		rs, err := ts.Client().Head(ts.URL + urlPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		if rs.StatusCode != http.StatusOK {
+			t.Errorf("%s: want %d; got %d", urlPath, http.StatusOK, rs.StatusCode)
+		}
+	}
+
+	code, _, body := ts.get(t, path)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("hunter2")) {
+		t.Fatalf("want the first GET to show the snippet; got %d", code)
+	}
+	if code, _, _ := ts.get(t, path); code != http.StatusGone {
+		t.Errorf("want %d; got %d", http.StatusGone, code)
+	}
+}
+
+func TestBurnAfterReadingConcurrently(t *testing.T) {
+	app := newTestApplication(t)
+	id, err := app.snippets.Insert(1, "Database password", "hunter2", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{Visibility: models.VisibilityUnlisted, BurnAfterReading: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := snippetPath(t, app, id)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// However many requests race to read the snippet, only one of them
+	// gets to see it.
+	const n = 20
+	codes := make(chan int, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			rs, err := ts.Client().Get(ts.URL + path)
+			if err != nil {
+				codes <- 0
+				return
+			}
+			rs.Body.Close()
+			codes <- rs.StatusCode
+		}()
+	}
+
+	counts := map[int]int{}
+	for i := 0; i < n; i++ {
+		counts[<-codes]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusGone] != n-1 {
+		t.Errorf("want 1 OK and %d Gone responses; got %v", n-1, counts)
+	}
+}
+
+func TestPasswordProtectedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+
+	alice := newTestServer(t, app.routes())
+	defer alice.Close()
+	aliceID := alice.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	bob := newTestServer(t, app.routes())
+	defer bob.Close()
+
+	paths := []string{}
+	for i := 0; i < 2; i++ {
+		id, err := app.snippets.Insert(aliceID, "Staging credentials", "hunter2", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{Visibility: models.VisibilityUnlisted, Password: "correct horse"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, snippetPath(t, app, id))
+	}
+	path := paths[0]
+
+	// Until it's unlocked, the snippet's page shows the unlock form and its
+	// other pages send you there.
+	code, _, body := bob.get(t, path)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("password protected")) {
+		t.Fatalf("want the unlock form; got %d", code)
+	}
+	if bytes.Contains(body, []byte("hunter2")) || bytes.Contains(body, []byte("Staging credentials")) {
+		t.Errorf("want nothing about the snippet on the unlock form")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	code, header, _ := bob.get(t, path+"/raw")
+	if code != http.StatusSeeOther || header.Get("Location") != path {
+		t.Errorf("want a redirect to %s; got %d %q", path, code, header.Get("Location"))
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		wantCode int
+	}{
+		{"Wrong password", "battery staple", http.StatusOK},
+		{"Right password", "correct horse", http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := bob.postForm(t, path+"/unlock", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code == http.StatusOK && !bytes.Contains(body, []byte("The password is incorrect")) {
+				t.Errorf("want body to contain an error")
+			}
+		})
+	}
+
+	// The snippet stays unlocked for the rest of the session, but the other
+	// snippet with the same password doesn't.
+	for _, urlPath := range []string{path, path + "/raw"} {
+		code, _, body = bob.get(t, urlPath)
+		if code != http.StatusOK || !bytes.Contains(body, []byte("hunter2")) {
+			t.Errorf("%s: want the unlocked snippet; got %d", urlPath, code)
+		}
+	}
+	_, _, body = bob.get(t, paths[1])
+	if !bytes.Contains(body, []byte("password protected")) {
+		t.Errorf("want the other snippet to stay locked")
+	}
+
+	// The owner never needs the password.
+	_, _, body = alice.get(t, paths[1])
+	if !bytes.Contains(body, []byte("hunter2")) {
+		t.Errorf("want the owner to see the snippet")
+	}
+
+	// Passwords set on the create form must be at least 8 characters long.
+	_, _, body = alice.get(t, "/snippet/create")
+	form := url.Values{}
+	form.Add("title", "Too short")
+	form.Add("content", "Content")
+	form.Add("password", "secret")
+	form.Add("expires", "1w")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body = alice.postForm(t, "/snippet/create", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("This field is too short")) {
+		t.Errorf("want a validation error; got %d", code)
+	}
+
+	// And at most 72 bytes, which bcrypt would otherwise silently cut
+	// short: 72 characters of Greek are 144 bytes.
+	form.Set("password", strings.Repeat("α", 72))
+	form.Set("csrf_token", extractCSRFToken(t, body))
+	code, _, body = alice.postForm(t, "/snippet/create", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("This field is too long (maximum is 72 bytes)")) {
+		t.Errorf("want a validation error; got %d", code)
+	}
+}
+
+func TestEncryptedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		content  string
+		wantCode int
+	}{
+		{"Plaintext", "package main", http.StatusOK},
+		{"Ciphertext", "q83vEjRWeJq8_-3u", http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Secret")
+			form.Add("content", tt.content)
+			form.Add("language", "go")
+			form.Add("encrypted", "true")
+			form.Add("expires", "1w")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if code == http.StatusOK && bytes.Contains(body, []byte(tt.content)) {
+				t.Errorf("want the content not to be sent back")
+			}
+		})
+	}
+
+	s, err := app.snippets.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Encrypted || s.Visibility != models.VisibilityUnlisted {
+		t.Errorf("want an unlisted encrypted snippet; got %+v", s)
+	}
+
+	// The ciphertext is handed to the browser as it is, without highlighting.
+	code, _, body := ts.get(t, snippetURL(s))
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("data-ciphertext='q83vEjRWeJq8_-3u'")) {
+		t.Errorf("want the ciphertext in the body")
+	}
+	if bytes.Contains(body, []byte(`<span class=`)) {
+		t.Errorf("want no highlighting")
+	}
+
+	// Nor can it be edited, since the server can't read it.
+	if code, _, _ := ts.get(t, snippetURL(s)+"/edit"); code != http.StatusBadRequest {
+		t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+	}
+}
@@ -2,12 +2,20 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/justinas/nosurf"
+	"github.com/petrostrak/code-snippet/pkg/forms"
+	"github.com/petrostrak/code-snippet/pkg/highlight"
 	"github.com/petrostrak/code-snippet/pkg/models"
 )
 
@@ -92,3 +100,452 @@ func (a *application) authenticatedUser(r *http.Request) *models.User {
 	}
 	return user
 }
+
+// The snippetFromURL helper loads the snippet named by the :slug URL
+// parameter. If there is no such snippet, or the current user isn't allowed
+// to see it, it sends a 404 Not Found response and ok is false, in which case
+// the caller should return straight away. A burn-after-reading snippet which
+// has already been read gets a 410 Gone response instead, except for its
+// owner.
+func (a *application) snippetFromURL(w http.ResponseWriter, r *http.Request) (s *models.Snippet, ok bool) {
+	s, err := a.snippets.GetBySlug(r.URL.Query().Get(":slug"))
+	if err == models.ErrNoRecord {
+		a.notFound(w)
+		return nil, false
+	} else if err != nil {
+		a.serverError(w, err)
+		return nil, false
+	}
+
+	// We respond to snippets the user can't see exactly as if they didn't
+	// exist, so that nobody can find out which slugs are taken.
+	user := a.authenticatedUser(r)
+	if !canView(user, s, true) {
+		a.notFound(w)
+		return nil, false
+	}
+
+	if s.BurnAfterReading && !s.ReadAt.IsZero() && !isOwner(user, s) {
+		a.clientError(w, http.StatusGone)
+		return nil, false
+	}
+
+	return s, true
+}
+
+// The readSnippet helper must be called before sending the content of a
+// snippet from snippetFromURL. For a burn-after-reading snippet, unless it's
+// the owner who is looking, it marks the snippet read. Only one request can
+// do that, so if another got there first it sends a 410 Gone response and ok
+// is false. burned is true when this request was the one which read it.
+//
+// pat answers HEAD requests with the GET handlers, but net/http never sends
+// the body of a response to one, so a HEAD request doesn't read the snippet.
+// Otherwise a link checker or a chat app's link preview would burn it before
+// the person it was meant for got to see it.
+func (a *application) readSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet) (burned, ok bool) {
+	if !s.BurnAfterReading || isOwner(a.authenticatedUser(r), s) || r.Method == http.MethodHead {
+		return false, true
+	}
+
+	err := a.snippets.MarkRead(s.ID)
+	if err == models.ErrAlreadyRead {
+		a.clientError(w, http.StatusGone)
+		return false, false
+	} else if err != nil {
+		a.serverError(w, err)
+		return false, false
+	}
+
+	// Make sure the content doesn't outlive this response in a cache.
+	w.Header().Set("Cache-Control", "no-store")
+	return true, true
+}
+
+// canView reports whether user (nil when nobody is logged in) may see s,
+// which was looked up by its slug or by its id. Anyone may see a public
+// snippet, and anyone with the link may see an unlisted one, but otherwise
+// only the owner may.
+func canView(user *models.User, s *models.Snippet, bySlug bool) bool {
+	switch {
+	case s.Visibility == models.VisibilityPublic:
+		return true
+	case s.Visibility == models.VisibilityUnlisted && bySlug:
+		return true
+	}
+	return isOwner(user, s)
+}
+
+// The snippetUnlocked helper reports whether the current user may see the
+// content of s as far as its password goes: either it doesn't have one, they
+// own it, or they've entered its password during this session.
+func (a *application) snippetUnlocked(r *http.Request, s *models.Snippet) bool {
+	if !s.Protected || isOwner(a.authenticatedUser(r), s) {
+		return true
+	}
+	return a.session.GetBool(r, unlockedKey(s))
+}
+
+// unlockedKey is the session key which records that a protected snippet has
+// been unlocked. Each snippet has its own, so that unlocking one snippet
+// doesn't unlock any other.
+func unlockedKey(s *models.Snippet) string {
+	return fmt.Sprintf("unlocked.%d", s.ID)
+}
+
+// The requireUnlocked helper is used by the pages under a snippet, like
+// /raw, which can't show the unlock form themselves. If the snippet is still
+// locked it redirects to the snippet's page, where the form is, and ok is
+// false.
+func (a *application) requireUnlocked(w http.ResponseWriter, r *http.Request, s *models.Snippet) (ok bool) {
+	if a.snippetUnlocked(r, s) {
+		return true
+	}
+	http.Redirect(w, r, snippetURL(s), http.StatusSeeOther)
+	return false
+}
+
+// isOwner reports whether user (nil when nobody is logged in) owns s.
+func isOwner(user *models.User, s *models.Snippet) bool {
+	return user != nil && user.ID == s.UserID
+}
+
+// The snippetURL helper returns the path of a snippet's page, /s/:slug. The
+// snippet's other pages, like /raw, are under the same path.
+func snippetURL(s *models.Snippet) string {
+	return "/s/" + s.Slug
+}
+
+// snippetOptions builds the models.SnippetOptions for a validated new
+// snippet form. Snippets which burn after reading, have a password or are
+// encrypted are meant for the people they're shared with, so they're never
+// listed: if one would have been public, it is made unlisted instead.
+func snippetOptions(form *forms.Form) models.SnippetOptions {
+	opts := models.SnippetOptions{
+		Visibility:       form.Get("visibility"),
+		BurnAfterReading: form.Get("burn") != "",
+		Password:         form.Get("password"),
+		Encrypted:        form.Get("encrypted") != "",
+	}
+	shared := opts.BurnAfterReading || opts.Password != "" || opts.Encrypted
+	if shared && (opts.Visibility == "" || opts.Visibility == models.VisibilityPublic) {
+		opts.Visibility = models.VisibilityUnlisted
+	}
+	return opts
+}
+
+// expiresAtLayout is the format of a custom expiry time, which is what a
+// datetime-local input posts. Like every time on the site, it's in UTC.
+const expiresAtLayout = "2006-01-02T15:04"
+
+// maxExpiry is how far in the future a snippet's expiry time can be set.
+const maxExpiry = 10 * 365 * 24 * time.Hour
+
+// snippetExpiry returns the expiry time chosen on a valid create form, or
+// the zero time if the snippet never expires.
+func snippetExpiry(form *forms.Form) time.Time {
+	switch form.Get("expires") {
+	case "never":
+		return time.Time{}
+	case "custom":
+		t, _ := time.Parse(expiresAtLayout, form.Get("expires_at"))
+		return t
+	default:
+		t, _ := forms.AddDuration(time.Now(), form.Get("expires"))
+		return t
+	}
+}
+
+// validateNewSnippet checks the fields used to create a snippet. It's shared
+// by the create form and the JSON API, so that both apply the same rules.
+func validateNewSnippet(form *forms.Form) {
+	form.Required("title", "content", "expires")
+	form.MaxLength("title", 100)
+
+	// A snippet expires after a duration like "1w", at a time of the user's
+	// choosing, or never.
+	switch form.Get("expires") {
+	case "never":
+	case "custom":
+		form.Required("expires_at")
+		form.FutureTime("expires_at", expiresAtLayout, maxExpiry)
+	default:
+		form.ValidDuration("expires", maxExpiry)
+	}
+
+	// The language is optional; leaving it blank means it will be detected
+	// from the content when the snippet is shown.
+	form.PermittedValues("language", highlight.IDs()...)
+
+	// The visibility is optional too, and defaults to public.
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	form.PermittedValues("burn", "true")
+
+	// The password is optional as well. bcrypt only looks at the first 72
+	// bytes of a password, so longer ones aren't allowed. That's bytes, not
+	// characters: a password in another alphabet may be far fewer than 72
+	// characters long.
+	form.MinLength("password", 8)
+	form.MaxBytes("password", 72)
+
+	// The content of an encrypted snippet is encrypted by the browser before
+	// it's posted, so all we can check is that it looks like ciphertext.
+	form.PermittedValues("encrypted", "true")
+	if form.Get("encrypted") != "" {
+		form.MatchesPattern("content", ciphertextRX)
+	}
+
+	validateTags(form)
+}
+
+// The limits on a snippet's tags. Tags end up in URLs like /tags/k8s, so
+// they're kept to lower-case letters, digits, dots, hyphens and underscores.
+const (
+	maxTags      = 5
+	maxTagLength = 30
+)
+
+var tagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// ciphertextRX matches the content of an encrypted snippet, which
+// ui/static/js/crypto.js encodes as unpadded URL-safe base64.
+var ciphertextRX = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseTags splits the tags field of a form, where tags are separated by
+// commas or spaces, into lower-case tags with any repeats removed.
+func parseTags(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range fields {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// validateTags checks the optional tags field: how many tags there are, how
+// long each one is and which characters they use.
+func validateTags(form *forms.Form) {
+	tags := parseTags(form.Get("tags"))
+	if len(tags) > maxTags {
+		form.Errors.Add("tags", fmt.Sprintf("Too many tags (maximum is %d)", maxTags))
+		return
+	}
+
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			form.Errors.Add("tags", fmt.Sprintf("The tag %q is too long (maximum is %d characters)", tag, maxTagLength))
+			return
+		}
+		if !tagRX.MatchString(tag) {
+			form.Errors.Add("tags", fmt.Sprintf("The tag %q may only contain letters, digits, dots, hyphens and underscores", tag))
+			return
+		}
+	}
+}
+
+// validateSnippetEdit checks the fields used to edit a snippet. These are the
+// same rules as validateNewSnippet, apart from the expiry and language which
+// can't be changed.
+func validateSnippetEdit(form *forms.Form) {
+	form.Required("title", "content")
+	form.MaxLength("title", 100)
+}
+
+// validateSearch checks the fields of a search, from either the search page
+// or the JSON API. The query itself may be blank, in which case there is
+// nothing to search for yet.
+func validateSearch(form *forms.Form) {
+	form.MaxLength("q", 200)
+	form.MaxLength("author", 255)
+	form.PermittedValues("language", highlight.IDs()...)
+}
+
+// searchQuery builds the models.SearchQuery for a validated search form.
+func searchQuery(form *forms.Form) models.SearchQuery {
+	return models.SearchQuery{
+		Text:     form.Get("q"),
+		Language: form.Get("language"),
+		Author:   form.Get("author"),
+	}
+}
+
+// listQuery builds the models.ListQuery for a page of snippets from the sort,
+// after and before query parameters, which are shared by the browse page and
+// the API. The error describes what's wrong with them, if anything.
+func listQuery(r *http.Request, limit int) (models.ListQuery, error) {
+	query := r.URL.Query()
+	q := models.ListQuery{Sort: query.Get("sort"), Limit: limit}
+
+	switch q.Sort {
+	case "":
+		q.Sort = models.SortNewest
+	case models.SortNewest, models.SortOldest, models.SortExpiring:
+	default:
+		return q, errors.New("sort must be newest, oldest or expiring")
+	}
+
+	after, before := query.Get("after"), query.Get("before")
+	if after != "" && before != "" {
+		return q, errors.New("after and before can't be used together")
+	}
+
+	var err error
+	if after != "" {
+		q.After, err = models.DecodeCursor(after)
+	} else if before != "" {
+		q.Before, err = models.DecodeCursor(before)
+	}
+	if err != nil {
+		return q, errors.New("invalid cursor")
+	}
+
+	return q, nil
+}
+
+// A snippetPage is one page of snippets, with the encoded cursors for the
+// previous and next pages. Prev and Next are empty when there's no such page.
+type snippetPage struct {
+	Snippets []*models.Snippet
+	Prev     string
+	Next     string
+}
+
+// The listSnippets helper fetches the page of snippets described by q. Like
+// our other paginated lists it asks for one snippet more than it shows, to
+// find out whether there's another page in the direction we're going. When
+// we're going backwards that extra snippet is the first one, not the last.
+func (a *application) listSnippets(q models.ListQuery) (*snippetPage, error) {
+	limit := q.Limit
+	q.Limit++
+	s, err := a.snippets.List(q)
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(s) > limit
+	hasPrev, hasNext := q.After != nil, more
+	if q.Before != nil {
+		if more {
+			s = s[1:]
+		}
+		hasPrev, hasNext = more, true
+	} else if more {
+		s = s[:limit]
+	}
+
+	p := &snippetPage{Snippets: s}
+	if len(s) == 0 {
+		// The snippets we came from may have expired since, but the
+		// cursor we were given still marks where they were.
+		if q.Before != nil {
+			p.Next = q.Before.Encode()
+		}
+		if q.After != nil {
+			p.Prev = q.After.Encode()
+		}
+		return p, nil
+	}
+
+	if hasPrev {
+		p.Prev = models.NewCursor(s[0], q.Sort).Encode()
+	}
+	if hasNext {
+		p.Next = models.NewCursor(s[len(s)-1], q.Sort).Encode()
+	}
+	return p, nil
+}
+
+// The cursorURL helper returns the URL of the current page with its "after"
+// or "before" query parameter (the key) set to cursor, and the other one
+// removed.
+func cursorURL(r *http.Request, key, cursor string) string {
+	q := r.URL.Query()
+	q.Del("after")
+	q.Del("before")
+	q.Set(key, cursor)
+
+	return r.URL.Path + "?" + q.Encode()
+}
+
+// The pageURL helper returns the URL of the current page with its "page"
+// query parameter set to page, for next and previous links.
+func pageURL(r *http.Request, page int) string {
+	q := r.URL.Query()
+	for k := range q {
+		// Drop the parameters which pat adds for the route's wildcards.
+		if strings.HasPrefix(k, ":") {
+			q.Del(k)
+		}
+	}
+	q.Set("page", strconv.Itoa(page))
+
+	return r.URL.Path + "?" + q.Encode()
+}
+
+// The snippetFilename helper builds a file name for a downloaded snippet from
+// its title, like "my-first-snippet.go". Runs of anything other than ASCII
+// letters and digits become a single hyphen, and a title with nothing usable
+// in it falls back to "snippet-<id>".
+func snippetFilename(s *models.Snippet) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s.Title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+
+	name := b.String()
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", s.ID)
+	}
+
+	return name + highlight.Extension(s.Language)
+}
+
+// The ownedSnippet helper works like snippetFromURL, but also checks that the
+// snippet belongs to the authenticated user, sending a 403 Forbidden response
+// if it belongs to someone else.
+func (a *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (s *models.Snippet, ok bool) {
+	s, ok = a.snippetFromURL(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !isOwner(a.authenticatedUser(r), s) {
+		a.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return s, true
+}
+
+// The renewableSnippet helper is like ownedSnippet, except that it also
+// finds the user's snippets which have expired but haven't been purged yet,
+// which snippetFromURL doesn't, so that they can be renewed.
+func (a *application) renewableSnippet(w http.ResponseWriter, r *http.Request) (s *models.Snippet, ok bool) {
+	s, err := a.snippets.GetOwnedBySlug(r.URL.Query().Get(":slug"), a.authenticatedUser(r).ID)
+	if err == nil {
+		return s, true
+	} else if err != models.ErrNoRecord {
+		a.serverError(w, err)
+		return nil, false
+	}
+
+	// Anybody else's snippet gets the same response as it would when
+	// editing or deleting it.
+	return a.ownedSnippet(w, r)
+}
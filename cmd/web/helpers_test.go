@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{
+			name:    "Language",
+			snippet: &models.Snippet{ID: 1, Title: "Hello, World!", Language: "go"},
+			want:    "hello-world.go",
+		},
+		{
+			name:    "No language",
+			snippet: &models.Snippet{ID: 1, Title: "An old silent pond", Language: ""},
+			want:    "an-old-silent-pond.txt",
+		},
+		{
+			name:    "No usable title",
+			snippet: &models.Snippet{ID: 7, Title: "¿¡ !?", Language: "markdown"},
+			want:    "snippet-7.md",
+		},
+		{
+			name:    "Surrounding punctuation",
+			snippet: &models.Snippet{ID: 1, Title: "  --deploy.sh--  ", Language: "bash"},
+			want:    "deploy-sh.sh",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snippetFilename(tt.snippet)
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
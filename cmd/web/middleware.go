@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/justinas/nosurf"
 	"github.com/petrostrak/code-snippet/pkg/models"
@@ -78,6 +79,64 @@ func noSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
+// The authenticateToken middleware is the API's equivalent of authenticate.
+// Rather than a session, it looks for a personal API token in an
+// "Authorization: Bearer <token>" header, and stores the token's owner in
+// the same contextKeyUser slot. Requests without the header carry on as
+// anonymous; requests with a bad token are rejected outright, so that a
+// revoked or expired token isn't mistaken for an anonymous read.
+func (a *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The "Bearer" scheme name is case-insensitive.
+		parts := strings.Fields(header)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			a.apiUnauthorized(w)
+			return
+		}
+
+		id, err := a.tokens.Authenticate(parts[1])
+		if err == models.ErrInvalidCredentials {
+			a.apiUnauthorized(w)
+			return
+		} else if err != nil {
+			a.apiServerError(w, err)
+			return
+		}
+
+		user, err := a.users.Get(id)
+		if err == models.ErrNoRecord {
+			a.apiUnauthorized(w)
+			return
+		} else if err != nil {
+			a.apiServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// The requireAPIUser middleware is the API's equivalent of
+// requireAuthenticatedUser. There's no login page to redirect to, so it
+// responds with 401 Unauthorized instead.
+func (a *application) requireAPIUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.authenticatedUser(r) == nil {
+			a.apiUnauthorized(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (a *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Check if a userID value exists in the session. If this isn't
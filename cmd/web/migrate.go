@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/petrostrak/code-snippet/migrations"
+)
+
+// The runMigrate() function implements the 'migrate' subcommand. It supports
+// 'migrate up', 'migrate down N' and 'migrate status'.
+func runMigrate(m *migrations.Migrator, args []string, infoLog *log.Logger, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down N | status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, mg := range applied {
+			infoLog.Printf("Applied migration %04d_%s", mg.Version, mg.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			infoLog.Print("Database schema is up to date")
+		}
+	case "down":
+		if len(args) != 2 {
+			return errors.New("usage: migrate down N")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of migrations %q", args[1])
+		}
+		reverted, err := m.Down(n)
+		for _, mg := range reverted {
+			infoLog.Printf("Reverted migration %04d_%s", mg.Version, mg.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, st := range status {
+			applied := "pending"
+			if !st.Applied.IsZero() {
+				applied = humanDate(st.Applied)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
+}
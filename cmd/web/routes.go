@@ -7,16 +7,6 @@ import (
 	"github.com/justinas/alice"
 )
 
-var (
-	// http.ServeMux is also a handler, which instead of providing
-	// a response itself passes the request on to a second handler.
-	mux *pat.PatternServeMux
-)
-
-func init() {
-	mux = pat.New()
-}
-
 // Update the signature for the routes() so that it returnst a
 // http.Handler instead of a *http.ServeMux
 func (a *application) routes() http.Handler {
@@ -33,19 +23,68 @@ func (a *application) routes() http.Handler {
 	// Add the authenticate() middleware to the chain.
 	dynamicMiddleware := alice.New(a.session.Enable, noSurf, a.authenticate)
 
-	// Use the http.NewServeMux() function to initialize a
-	// new servemux, then register the home function as the
-	// handler for the "/" URL pattern.
+	// Use the pat.New() function to initialize a new servemux, then register
+	// the home function as the handler for the "/" URL pattern. The servemux
+	// is created here rather than as a package-level variable, so that each
+	// application instance (like the ones our tests create) gets its own.
+	mux := pat.New()
 	mux.Get("/", dynamicMiddleware.ThenFunc(a.home))
 
 	// Add the requireAuthenticatedUser middleware to the chain
 	mux.Get("/snippet/create", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.createSnippetForm))
 	mux.Post("/snippet/create", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.createSnippet))
 
-	// If we don't want to use alice package for managing our middlewares, then we
-	// can simply wrap the handler functions with the session middleware instead
-	// like so
-	mux.Get("/snippet/:id", a.session.Enable(http.HandlerFunc(a.showSnippet)))
+	// Snippets are reached through their random slug rather than their id,
+	// so that they can't be found by counting up. The show page uses the full
+	// dynamic chain so that it knows who the authenticated user is, and can
+	// offer the owner the edit and delete forms. The raw and download routes
+	// only send the snippet's content, but they still need the session to
+	// know whether a private snippet's owner is asking for it.
+	mux.Get("/s/:slug", dynamicMiddleware.ThenFunc(a.showSnippet))
+	mux.Get("/s/:slug/history", dynamicMiddleware.ThenFunc(a.snippetHistory))
+	mux.Get("/s/:slug/diff/:from/:to", dynamicMiddleware.ThenFunc(a.snippetDiff))
+	mux.Get("/s/:slug/raw", dynamicMiddleware.ThenFunc(a.rawSnippet))
+	mux.Get("/s/:slug/download", dynamicMiddleware.ThenFunc(a.downloadSnippet))
+
+	// A password-protected snippet's page shows an unlock form, which is
+	// posted here.
+	mux.Post("/s/:slug/unlock", dynamicMiddleware.ThenFunc(a.unlockSnippet))
+
+	// Only the owner of a snippet may edit, delete or extend it. The handlers check
+	// this themselves and respond with 403 Forbidden otherwise.
+	mux.Get("/s/:slug/edit", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.editSnippetForm))
+	mux.Post("/s/:slug/edit", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.editSnippet))
+	mux.Post("/s/:slug/delete", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.deleteSnippet))
+	mux.Post("/s/:slug/extend", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.extendSnippet))
+
+	// Any signed-in user may fork a snippet they can see into a copy of
+	// their own.
+	mux.Post("/s/:slug/fork", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.forkSnippet))
+
+	// Stars are added and removed with POSTs, so that they're protected
+	// from CSRF like every other form.
+	mux.Post("/s/:slug/star", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.starSnippet))
+	mux.Post("/s/:slug/unstar", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.unstarSnippet))
+
+	// The old /snippet/:id URLs, and the pages under them, redirect to the
+	// slug URLs, but only for public snippets. These come after
+	// /snippet/create so that it isn't mistaken for an id.
+	mux.Get("/snippet/:id", http.HandlerFunc(a.redirectSnippet))
+	mux.Get("/snippet/:id/", http.HandlerFunc(a.redirectSnippet))
+
+	mux.Get("/snippets", dynamicMiddleware.ThenFunc(a.browse))
+	mux.Get("/tags/:tag", dynamicMiddleware.ThenFunc(a.tag))
+	mux.Get("/search", dynamicMiddleware.ThenFunc(a.search))
+
+	// The JSON API has its own middleware chain, without sessions or CSRF
+	// protection. See api.go.
+	apiMiddleware := alice.New(a.authenticateToken)
+	mux.Get("/api/v1/snippets", apiMiddleware.ThenFunc(a.apiListSnippets))
+	mux.Post("/api/v1/snippets", apiMiddleware.Append(a.requireAPIUser).ThenFunc(a.apiCreateSnippet))
+	mux.Get("/api/v1/snippets/:id", apiMiddleware.ThenFunc(a.apiShowSnippet))
+	mux.Put("/api/v1/snippets/:id", apiMiddleware.Append(a.requireAPIUser).ThenFunc(a.apiUpdateSnippet))
+	mux.Del("/api/v1/snippets/:id", apiMiddleware.Append(a.requireAPIUser).ThenFunc(a.apiDeleteSnippet))
+	mux.Get("/api/v1/search", apiMiddleware.ThenFunc(a.apiSearchSnippets))
 
 	// User routes
 	mux.Get("/user/signup", dynamicMiddleware.ThenFunc(a.signupUserForm))
@@ -54,6 +93,11 @@ func (a *application) routes() http.Handler {
 	mux.Post("/user/login", dynamicMiddleware.ThenFunc(a.loginUser))
 
 	// Add the requireAuthenticatedUser middleware to the chain
+	mux.Get("/user/snippets", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.userSnippets))
+	mux.Get("/user/starred", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.userStarred))
+	mux.Get("/user/settings", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.settings))
+	mux.Post("/user/settings/tokens", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.createToken))
+	mux.Post("/user/settings/tokens/:id/revoke", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.revokeToken))
 	mux.Post("/user/logout", dynamicMiddleware.Append(a.requireAuthenticatedUser).ThenFunc(a.logoutUser))
 
 	// Create a file server which serves files out of the ./ui/static/ dir.
@@ -0,0 +1,80 @@
+package main
+
+import "time"
+
+// The startSweeper() method starts a background goroutine which purges
+// expired snippets from the store every interval. The handlers already
+// ignore expired snippets, so this is only about keeping the snippets table
+// from growing forever. If archive is true, the snippets are moved to the
+// archive rather than deleted.
+//
+// It returns a function which stops the sweeper, and waits for it to finish
+// the batch it's working on, so that it can be called on shutdown before the
+// database connection pool is closed.
+func (a *application) startSweeper(interval time.Duration, batchSize int, archive bool) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			a.sweep(batchSize, archive, stop)
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// The sweep() method purges expired snippets batchSize at a time, so that no
+// single transaction holds its locks for long, until there are none left or
+// stop is closed. It logs and returns the number of snippets it purged.
+func (a *application) sweep(batchSize int, archive bool, stop <-chan struct{}) int {
+	total := 0
+
+	for {
+		n, err := a.snippets.PurgeExpired(batchSize, archive)
+		if err != nil {
+			a.errorLog.Print(err)
+			break
+		}
+		total += n
+
+		if n < batchSize || stopped(stop) {
+			break
+		}
+	}
+
+	if total > 0 {
+		action := "Deleted"
+		if archive {
+			action = "Archived"
+		}
+		a.infoLog.Printf("%s %d expired snippets", action, total)
+	}
+
+	return total
+}
+
+// stopped reports whether the stop channel has been closed, without
+// blocking.
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestSweep(t *testing.T) {
+	app := newTestApplication(t)
+
+	var buf bytes.Buffer
+	app.infoLog = log.New(&buf, "", 0)
+
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	for _, expires := range []time.Time{past, past, past, past, past, future} {
+		if _, err := app.snippets.Insert(1, "Title", "Content", "", expires, models.SnippetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := app.sweep(2, false, make(chan struct{})); n != 5 {
+		t.Errorf("want 5 snippets swept; got %d", n)
+	}
+	if _, err := app.snippets.Get(6); err != nil {
+		t.Errorf("want the live snippet to be left alone; got %v", err)
+	}
+	if got := buf.String(); !strings.Contains(got, "Deleted 5 expired snippets") {
+		t.Errorf("want the count to be logged; got %q", got)
+	}
+
+	// A second sweep has nothing to do, and says nothing.
+	buf.Reset()
+	if n := app.sweep(2, false, make(chan struct{})); n != 0 {
+		t.Errorf("want nothing swept; got %d", n)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("want nothing logged; got %q", buf.String())
+	}
+}
+
+func TestStartSweeper(t *testing.T) {
+	app := newTestApplication(t)
+
+	if _, err := app.snippets.Insert(1, "Title", "Content", "", time.Now().Add(-time.Minute), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sweeper runs straight away, and then once an hour, so stopping it
+	// must not have to wait for the next tick.
+	stop := app.startSweeper(time.Hour, 10, true)
+
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the sweeper didn't stop")
+	}
+
+	snippets, err := app.snippets.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 0 {
+		t.Errorf("want the expired snippet to be swept; got %d snippets", len(snippets))
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/petrostrak/code-snippet/pkg/diff"
 	"github.com/petrostrak/code-snippet/pkg/forms"
+	"github.com/petrostrak/code-snippet/pkg/highlight"
+	"github.com/petrostrak/code-snippet/pkg/markdown"
 	"github.com/petrostrak/code-snippet/pkg/models"
+	"github.com/petrostrak/code-snippet/pkg/search"
 )
 
 // An important thing to explain is that Go’s html/template package allows
@@ -22,12 +26,28 @@ import (
 // data that we want to pass to our HTML templates.
 type templateData struct {
 	AuthenticatedUser *models.User
+	Burned            bool
 	CSRFToken         string
 	CurrentYear       int
+	Diff              []diff.Hunk
 	Form              *forms.Form
 	Flash             string
+	Forks             int
+	Languages         []highlight.Language
+	MostStarred       []*models.Snippet
+	NewToken          string
+	NextURL           string
+	Original          *models.Snippet
+	PrevURL           string
+	Revisions         []*models.Revision
+	ShowSource        bool
+	Sort              string
+	Tag               string
+	Tags              []*models.Tag
 	Snippet           *models.Snippet
 	Snippets          []*models.Snippet
+	Starred           bool
+	Tokens            []*models.Token
 }
 
 // Create a humanDate function which returns a nicely formatted string
@@ -42,12 +62,35 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// The sub function subtracts b from a. Templates have no arithmetic of their
+// own, so we need this to link each revision to the one before it.
+func sub(a, b int) int {
+	return a - b
+}
+
+// The markTerms and excerpt functions highlight the words of a search query
+// in a snippet's title and content, for the search results page.
+func markTerms(text, query string) template.HTML {
+	return search.Highlight(text, search.Terms(query))
+}
+
+func excerpt(text, query string) template.HTML {
+	return search.Excerpt(text, search.Terms(query), 200)
+}
+
 // Initialize a template.FuncMap object and store it in a global
 // variable. This is essentially a string-keyed map which acts as
 // a lookup between the names of one custom template functions and
 // the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"highlight":    highlight.HTML,
+	"humanDate":    humanDate,
+	"excerpt":      excerpt,
+	"languageName": highlight.Name,
+	"markTerms":    markTerms,
+	"markdown":     markdown.HTML,
+	"snippetURL":   snippetURL,
+	"sub":          sub,
 }
 
 // Each and every time we render a web page, our application must read
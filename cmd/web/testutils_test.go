@@ -2,11 +2,22 @@ package main
 
 import (
 	"html"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/golangcollege/sessions"
+	"github.com/petrostrak/code-snippet/pkg/models/memory"
 )
 
-var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='{{.CSRFToken}}'>`)
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
 
 func extractCSRFToken(t *testing.T, body []byte) string {
 	// Use the FindSubmatch method to extract the token from the HTML body.
@@ -19,3 +30,158 @@ func extractCSRFToken(t *testing.T, body []byte) string {
 	}
 	return html.UnescapeString(string(matches[1]))
 }
+
+// Create a newTestApplication helper which returns an instance of our
+// application struct backed by the in-memory stores, so that the handlers
+// can be exercised without a database.
+func newTestApplication(t *testing.T) *application {
+	// Create an instance of the template cache.
+	templateCache, err := newTemplateCache("./../../ui/html/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a session manager instance, with the same settings as production.
+	session := sessions.New([]byte("3dSm5MnygFHh7XidAtbskXrjbwfoJcbJ"))
+	session.Lifetime = 12 * time.Hour
+	session.Secure = true
+
+	db := memory.NewDB()
+
+	return &application{
+		errorLog:      log.New(ioutil.Discard, "", 0),
+		infoLog:       log.New(ioutil.Discard, "", 0),
+		session:       session,
+		snippets:      &memory.SnippetModel{DB: db},
+		templateCache: templateCache,
+		tokens:        &memory.TokenModel{DB: db},
+		users:         &memory.UserModel{DB: db},
+	}
+}
+
+// Define a custom testServer type which anonymously embeds a httptest.Server
+// instance.
+type testServer struct {
+	*httptest.Server
+}
+
+// Create a newTestServer helper which initalizes and returns a new instance
+// of our custom testServer type.
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewTLSServer(h)
+
+	// Initialize a new cookie jar and add it to the client, so that response
+	// cookies are stored and then sent with subsequent requests.
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	// Disable redirect-following for the client, so that we can check the
+	// first response sent by the server.
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// Implement a get method on our custom testServer type. This makes a GET
+// request to a given url path on the test server, and returns the response
+// status code, headers and body.
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byte) {
+	rs, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, body
+}
+
+// Create a postForm method for sending POST requests to the test server.
+// The final parameter to this method is a url.Values object which can contain
+// any data that you want to send in the request body.
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, []byte) {
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, body
+}
+
+// The apiRequest method sends a request to the JSON API. The body is sent as
+// is, and if token is non-empty it is sent as a bearer token.
+func (ts *testServer) apiRequest(t *testing.T, method, urlPath, body, token string) (int, http.Header, []byte) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	rsBody, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, rsBody
+}
+
+// signupAndLogin creates a user with the given name and email directly in the
+// application's store and logs them in through the login form, so that
+// subsequent requests from the test client are authenticated. It returns the
+// new user's ID.
+func (ts *testServer) signupAndLogin(t *testing.T, app *application, name, email string) int {
+	password := "validPa$$word"
+	if err := app.users.Insert(name, email, password); err != nil {
+		t.Fatal(err)
+	}
+	id, err := app.users.Authenticate(email, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+
+	return id
+}
+
+// The snippetPath helper returns the path of the page of the (live) snippet
+// with the given id, which is under its random slug.
+func snippetPath(t *testing.T, app *application, id int) string {
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snippetURL(s)
+}
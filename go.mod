@@ -3,12 +3,19 @@ module github.com/petrostrak/code-snippet
 go 1.17
 
 require (
+	github.com/alecthomas/chroma v0.10.0
 	github.com/bmizerany/pat v0.0.0-20210406213842-e4b6760bdd6f
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/golangcollege/sessions v1.2.0
 	github.com/justinas/alice v1.2.0
 	github.com/justinas/nosurf v1.1.1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/yuin/goldmark v1.4.11
 	golang.org/x/crypto v0.0.0-20200317142112-1b76d66859c6
 )
 
-require golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
+require (
+	github.com/dlclark/regexp2 v1.4.0 // indirect
+	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
+)
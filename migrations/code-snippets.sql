@@ -1,55 +0,0 @@
--- Create a new UTF-8 `snippetbox` database.
-CREATE DATABASE codesnippet CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
--- Switch to using the `snippetbox` database.
-USE codesnippet;
-
--- Create a `snippets` table.
-CREATE TABLE snippets (
-    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    title VARCHAR(100) NOT NULL,
-    content TEXT NOT NULL,
-    created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
-);
--- Add an index on the created column.
-CREATE INDEX idx_snippets_created ON snippets(created);
-
--- Add some dummy records (which we'll use in the next couple of chapters).
-INSERT INTO snippets (title, content, created, expires) 
-VALUES (
-    'An old silent pond',
-    'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.',
-    UTC_TIMESTAMP(),
-    DATE_ADD(UTC_TIMESTAMP(), INTERVAL 365 DAY)
-);
-
-INSERT INTO snippets (title, content, created, expires) 
-VALUES (
-    'Over the wintry forest',
-    'Over the wintry\nforest, winds howl in rage\nwith no leaves to blow.',
-    UTC_TIMESTAMP(),
-    DATE_ADD(UTC_TIMESTAMP(), INTERVAL 365 DAY)
-);
-
-INSERT INTO snippets (title, content, created, expires) 
-VALUES (
-    'First autumn morning',
-    'First autumn morning\nthe mirror I stare into\nshows my father''s face.',
-    UTC_TIMESTAMP(),
-    DATE_ADD(UTC_TIMESTAMP(), INTERVAL 7 DAY)
-);
-
-CREATE USER 'web'@'localhost';
-GRANT SELECT, INSERT ON codesnippet.* TO 'web'@'localhost';
--- Important: Make sure to swap 'pass' with a password of your own choosing.
-ALTER USER 'web'@'localhost' IDENTIFIED BY 'pass';
-
-CREATE TABLE users (
-id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-name VARCHAR(255) NOT NULL,
-email VARCHAR(255) NOT NULL,
-hashed_password CHAR(60) NOT NULL,
-created DATETIME NOT NULL
-);
-
-ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
\ No newline at end of file
@@ -0,0 +1,280 @@
+// Package migrations embeds the numbered schema migrations for each supported
+// database driver and applies them, keeping track of what has been run in a
+// schema_migrations table.
+//
+// Migration files live in a directory named after the driver (mysql,
+// postgres or sqlite3) and are named like 0001_create_snippets.up.sql and
+// 0001_create_snippets.down.sql.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite3/*.sql
+var files embed.FS
+
+var filenameRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration holds the SQL to apply and revert a single schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and when it was applied. Applied is the zero
+// time for migrations which are still pending.
+type Status struct {
+	*Migration
+	Applied time.Time
+}
+
+// Load returns the embedded migrations for a driver, sorted by version.
+func Load(driver string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(files, driver)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: no migrations for driver %q", driver)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		matches := filenameRX.FindStringSubmatch(e.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("migrations: invalid file name %q", e.Name())
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := files.ReadFile(path.Join(driver, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = mg
+		} else if mg.Name != matches[2] {
+			return nil, fmt.Errorf("migrations: version %d is used by both %q and %q", version, mg.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			mg.Up = string(b)
+		} else {
+			mg.Down = string(b)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migrations: %04d_%s needs both an up and a down file", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, mg)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies the embedded migrations for Driver to a database.
+type Migrator struct {
+	DB     *sql.DB
+	Driver string
+}
+
+// Up applies every pending migration in order and returns the ones that were
+// applied.
+func (m *Migrator) Up() ([]*Migration, error) {
+	status, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []*Migration{}
+	for _, st := range status {
+		if !st.Applied.IsZero() {
+			continue
+		}
+
+		err := m.run(st.Migration.Up,
+			`INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)`,
+			st.Version, st.Name, time.Now().UTC())
+		if err != nil {
+			return applied, fmt.Errorf("migrations: applying %04d_%s: %w", st.Version, st.Name, err)
+		}
+		applied = append(applied, st.Migration)
+	}
+
+	return applied, nil
+}
+
+// Down reverts the n most recently applied migrations, newest first, and
+// returns the ones that were reverted.
+func (m *Migrator) Down(n int) ([]*Migration, error) {
+	status, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []*Migration{}
+	for i := len(status) - 1; i >= 0 && len(reverted) < n; i-- {
+		st := status[i]
+		if st.Applied.IsZero() {
+			continue
+		}
+
+		err := m.run(st.Migration.Down,
+			`DELETE FROM schema_migrations WHERE version = ?`,
+			st.Version)
+		if err != nil {
+			return reverted, fmt.Errorf("migrations: reverting %04d_%s: %w", st.Version, st.Name, err)
+		}
+		reverted = append(reverted, st.Migration)
+	}
+
+	return reverted, nil
+}
+
+// Status returns every known migration along with the time it was applied.
+func (m *Migrator) Status() ([]*Status, error) {
+	migrations, err := Load(m.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var t time.Time
+		if err := rows.Scan(&version, &t); err != nil {
+			return nil, err
+		}
+		applied[version] = t
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]*Status, len(migrations))
+	for i, mg := range migrations {
+		status[i] = &Status{Migration: mg, Applied: applied[mg.Version]}
+	}
+
+	return status, nil
+}
+
+// createTable creates the schema_migrations table if it doesn't exist yet.
+func (m *Migrator) createTable() error {
+	applied := "DATETIME"
+	if m.Driver == "postgres" {
+		applied = "TIMESTAMPTZ"
+	}
+
+	_, err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied ` + applied + ` NOT NULL
+	)`)
+	return err
+}
+
+// run executes the statements in script followed by the bookkeeping query
+// inside a single transaction. Note that MySQL implicitly commits DDL
+// statements, so a failed migration may be left partially applied there.
+func (m *Migrator) run(script, query string, args ...interface{}) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range split(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(m.rebind(query), args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rebind rewrites the ? placeholders in query to the $N form that PostgreSQL
+// expects.
+func (m *Migrator) rebind(query string) string {
+	if m.Driver != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// split breaks a script into individual statements, so that it can be run
+// without enabling multi-statement support in the MySQL driver. Statements
+// are expected to end with a semicolon at the end of a line.
+func split(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if cur.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+
+		cur.WriteString(line)
+		cur.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		}
+	}
+
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+
+	return stmts
+}
@@ -0,0 +1,116 @@
+package migrations
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestLoad(t *testing.T) {
+	// Every driver must ship the same set of migrations, so that the schema
+	// doesn't drift between backends.
+	var want []string
+	for _, driver := range []string{"mysql", "postgres", "sqlite3"} {
+		migrations, err := Load(driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, mg := range migrations {
+			names = append(names, mg.Name)
+		}
+
+		if want == nil {
+			want = names
+		} else if !reflect.DeepEqual(names, want) {
+			t.Errorf("%s: want migrations %v; got %v", driver, want, names)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := &Migrator{DB: db, Driver: "sqlite3"}
+
+	all, err := Load("sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(all) {
+		t.Errorf("want %d migrations applied; got %d", len(all), len(applied))
+	}
+
+	// Running up again should be a no-op.
+	applied, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("want no migrations applied; got %d", len(applied))
+	}
+
+	reverted, err := m.Down(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != all[len(all)-1].Version {
+		t.Errorf("want the latest migration reverted; got %v", reverted)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, st := range status {
+		pending := st.Applied.IsZero()
+		if wantPending := i == len(status)-1; pending != wantPending {
+			t.Errorf("%04d_%s: want pending %v; got %v", st.Version, st.Name, wantPending, pending)
+		}
+	}
+
+	// Reverting everything should leave the database empty apart from the
+	// schema_migrations table.
+	if _, err := m.Down(len(all)); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want no tables left; got %d", n)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	script := `-- A comment
+CREATE TABLE a (
+    id INTEGER
+);
+
+CREATE INDEX idx_a ON a(id);
+`
+	want := []string{
+		"CREATE TABLE a (\n    id INTEGER\n);",
+		"CREATE INDEX idx_a ON a(id);",
+	}
+
+	if got := split(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP INDEX snippets_ft_search;
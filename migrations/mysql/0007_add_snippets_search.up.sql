@@ -0,0 +1 @@
+ALTER TABLE snippets ADD FULLTEXT INDEX snippets_ft_search (title, content);
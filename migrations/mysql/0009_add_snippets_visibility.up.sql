@@ -0,0 +1,9 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(8) NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16) NULL;
+
+-- Give each existing snippet a random slug, in the same URL-safe base64
+-- alphabet that models.NewSlug() uses.
+UPDATE snippets SET slug = LEFT(REPLACE(REPLACE(TO_BASE64(RANDOM_BYTES(9)), '+', '-'), '/', '_'), 12);
+
+ALTER TABLE snippets MODIFY slug VARCHAR(16) NOT NULL;
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
@@ -0,0 +1,5 @@
+ALTER TABLE snippets DROP INDEX snippets_ft_search;
+ALTER TABLE snippets DROP COLUMN search_content;
+ALTER TABLE snippets DROP COLUMN search_title;
+ALTER TABLE snippets ADD FULLTEXT INDEX snippets_ft_search (title, content);
+ALTER TABLE snippets DROP COLUMN encrypted;
@@ -0,0 +1,9 @@
+ALTER TABLE snippets ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Encrypted snippets are nothing but ciphertext, so the FULLTEXT index is
+-- moved onto stored copies of the title and content which are left empty
+-- for them. A FULLTEXT index can't be built on virtual generated columns.
+ALTER TABLE snippets DROP INDEX snippets_ft_search;
+ALTER TABLE snippets ADD COLUMN search_title VARCHAR(100) GENERATED ALWAYS AS (IF(encrypted, '', title)) STORED;
+ALTER TABLE snippets ADD COLUMN search_content TEXT GENERATED ALWAYS AS (IF(encrypted, '', content)) STORED;
+ALTER TABLE snippets ADD FULLTEXT INDEX snippets_ft_search (search_title, search_content);
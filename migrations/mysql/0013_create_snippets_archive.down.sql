@@ -0,0 +1,2 @@
+DROP INDEX idx_snippets_expires ON snippets;
+DROP TABLE snippets_archive;
@@ -0,0 +1,18 @@
+-- The expiry sweeper can move expired snippets here rather than delete them.
+-- Only the snippet's final state is kept, not its revisions or tags.
+CREATE TABLE snippets_archive (
+    id INTEGER NOT NULL PRIMARY KEY,
+    user_id INTEGER NULL,
+    slug VARCHAR(16) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(32) NOT NULL,
+    visibility VARCHAR(8) NOT NULL,
+    encrypted BOOLEAN NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    archived DATETIME NOT NULL
+);
+
+-- The sweeper looks for expired snippets in order of expiry.
+CREATE INDEX idx_snippets_expires ON snippets(expires);
@@ -0,0 +1,2 @@
+DROP INDEX idx_snippets_forked_from ON snippets;
+ALTER TABLE snippets DROP COLUMN forked_from;
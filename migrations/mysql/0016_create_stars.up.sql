@@ -0,0 +1,12 @@
+-- A user can star each snippet once. When they starred it is kept for the
+-- home page's most starred snippets of the week.
+CREATE TABLE stars (
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, user_id),
+    CONSTRAINT fk_stars_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id),
+    CONSTRAINT fk_stars_user_id FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_stars_user_id ON stars(user_id, created);
@@ -0,0 +1,2 @@
+DROP INDEX snippets_search_idx;
+ALTER TABLE snippets DROP COLUMN search;
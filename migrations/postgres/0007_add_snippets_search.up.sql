@@ -0,0 +1,8 @@
+-- The 'simple' configuration doesn't stem words or drop stop words, which
+-- suits code better than a language-specific one. Title matches are weighted
+-- above content matches when ranking results.
+ALTER TABLE snippets ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B')
+) STORED;
+
+CREATE INDEX snippets_search_idx ON snippets USING GIN (search);
@@ -0,0 +1,9 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(8) NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16) NULL;
+
+-- Give each existing snippet a random slug. These are hex rather than the
+-- base64 that models.NewSlug() uses, which is just as safe in a URL.
+UPDATE snippets SET slug = SUBSTRING(REPLACE(gen_random_uuid()::TEXT, '-', '') FOR 16);
+
+ALTER TABLE snippets ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
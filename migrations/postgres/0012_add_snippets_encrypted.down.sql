@@ -0,0 +1,8 @@
+DROP INDEX snippets_search_idx;
+ALTER TABLE snippets DROP COLUMN search;
+ALTER TABLE snippets ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B')
+) STORED;
+
+CREATE INDEX snippets_search_idx ON snippets USING GIN (search);
+ALTER TABLE snippets DROP COLUMN encrypted;
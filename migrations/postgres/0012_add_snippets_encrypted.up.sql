@@ -0,0 +1,13 @@
+ALTER TABLE snippets ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Encrypted snippets are nothing but ciphertext, so the search column is
+-- redefined to leave them out of the index.
+DROP INDEX snippets_search_idx;
+ALTER TABLE snippets DROP COLUMN search;
+ALTER TABLE snippets ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    CASE WHEN encrypted THEN ''::tsvector
+    ELSE setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', content), 'B')
+    END
+) STORED;
+
+CREATE INDEX snippets_search_idx ON snippets USING GIN (search);
@@ -0,0 +1,2 @@
+-- Snippets which never expire have a NULL expiry time.
+ALTER TABLE snippets ALTER COLUMN expires DROP NOT NULL;
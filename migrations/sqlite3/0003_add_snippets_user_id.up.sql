@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
@@ -0,0 +1,5 @@
+DROP TRIGGER snippets_fts_ai;
+DROP TRIGGER snippets_fts_au;
+DROP TRIGGER snippets_fts_bd;
+DROP TRIGGER snippets_fts_bu;
+DROP TABLE snippets_fts;
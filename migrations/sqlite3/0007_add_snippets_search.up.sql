@@ -0,0 +1,13 @@
+-- snippets_fts is an external content FTS4 table: it only stores the index,
+-- and reads the text from the snippets table. The triggers keep the index up
+-- to date. Each trigger is written on a single line, because the migration
+-- runner splits scripts into statements at lines ending in a semicolon.
+CREATE VIRTUAL TABLE snippets_fts USING fts4(content="snippets", title, content);
+
+CREATE TRIGGER snippets_fts_bu BEFORE UPDATE ON snippets BEGIN DELETE FROM snippets_fts WHERE docid = old.id; END;
+CREATE TRIGGER snippets_fts_bd BEFORE DELETE ON snippets BEGIN DELETE FROM snippets_fts WHERE docid = old.id; END;
+CREATE TRIGGER snippets_fts_au AFTER UPDATE ON snippets BEGIN INSERT INTO snippets_fts (docid, title, content) VALUES (new.id, new.title, new.content); END;
+CREATE TRIGGER snippets_fts_ai AFTER INSERT ON snippets BEGIN INSERT INTO snippets_fts (docid, title, content) VALUES (new.id, new.title, new.content); END;
+
+-- Index the snippets which already exist.
+INSERT INTO snippets_fts (snippets_fts) VALUES ('rebuild');
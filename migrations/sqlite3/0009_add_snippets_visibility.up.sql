@@ -0,0 +1,9 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(8) NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16) NULL;
+
+-- Give each existing snippet a random slug. These are hex rather than the
+-- base64 that models.NewSlug() uses, which is just as safe in a URL. SQLite
+-- can't add NOT NULL to an existing column, but Insert() always sets one.
+UPDATE snippets SET slug = lower(hex(randomblob(8)));
+
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
@@ -0,0 +1,11 @@
+DROP TRIGGER snippets_fts_ai;
+DROP TRIGGER snippets_fts_au;
+DROP TRIGGER snippets_fts_bd;
+DROP TRIGGER snippets_fts_bu;
+
+CREATE TRIGGER snippets_fts_bu BEFORE UPDATE ON snippets BEGIN DELETE FROM snippets_fts WHERE docid = old.id; END;
+CREATE TRIGGER snippets_fts_bd BEFORE DELETE ON snippets BEGIN DELETE FROM snippets_fts WHERE docid = old.id; END;
+CREATE TRIGGER snippets_fts_au AFTER UPDATE ON snippets BEGIN INSERT INTO snippets_fts (docid, title, content) VALUES (new.id, new.title, new.content); END;
+CREATE TRIGGER snippets_fts_ai AFTER INSERT ON snippets BEGIN INSERT INTO snippets_fts (docid, title, content) VALUES (new.id, new.title, new.content); END;
+
+ALTER TABLE snippets DROP COLUMN encrypted;
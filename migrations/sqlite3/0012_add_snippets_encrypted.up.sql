@@ -0,0 +1,13 @@
+ALTER TABLE snippets ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Encrypted snippets are nothing but ciphertext, so the triggers which keep
+-- snippets_fts up to date are recreated to leave them out of the index.
+DROP TRIGGER snippets_fts_ai;
+DROP TRIGGER snippets_fts_au;
+DROP TRIGGER snippets_fts_bd;
+DROP TRIGGER snippets_fts_bu;
+
+CREATE TRIGGER snippets_fts_bu BEFORE UPDATE ON snippets WHEN NOT old.encrypted BEGIN DELETE FROM snippets_fts WHERE docid = old.id; END;
+CREATE TRIGGER snippets_fts_bd BEFORE DELETE ON snippets WHEN NOT old.encrypted BEGIN DELETE FROM snippets_fts WHERE docid = old.id; END;
+CREATE TRIGGER snippets_fts_au AFTER UPDATE ON snippets WHEN NOT new.encrypted BEGIN INSERT INTO snippets_fts (docid, title, content) VALUES (new.id, new.title, new.content); END;
+CREATE TRIGGER snippets_fts_ai AFTER INSERT ON snippets WHEN NOT new.encrypted BEGIN INSERT INTO snippets_fts (docid, title, content) VALUES (new.id, new.title, new.content); END;
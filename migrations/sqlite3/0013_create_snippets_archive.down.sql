@@ -0,0 +1,2 @@
+DROP INDEX idx_snippets_expires;
+DROP TABLE snippets_archive;
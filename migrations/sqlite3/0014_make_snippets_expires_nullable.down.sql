@@ -0,0 +1,31 @@
+CREATE TABLE snippets_new (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    user_id INTEGER NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    visibility VARCHAR(8) NOT NULL DEFAULT 'public',
+    slug VARCHAR(16) NULL,
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    read_at DATETIME NULL,
+    hashed_password CHAR(60) NULL,
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO snippets_new (id, title, content, created, expires, user_id, language, visibility, slug, burn_after_reading, read_at, hashed_password, encrypted)
+SELECT id, title, content, created, COALESCE(expires, '9999-12-31 23:59:59'), user_id, language, visibility, slug, burn_after_reading, read_at, hashed_password, encrypted FROM snippets;
+
+DROP TABLE snippets;
+ALTER TABLE snippets_new RENAME TO snippets;
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+CREATE INDEX idx_snippets_expires ON snippets(expires);
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
+
+CREATE TRIGGER snippets_fts_bu BEFORE UPDATE ON snippets WHEN NOT old.encrypted BEGIN DELETE FROM snippets_fts WHERE docid = old.id; END;
+CREATE TRIGGER snippets_fts_bd BEFORE DELETE ON snippets WHEN NOT old.encrypted BEGIN DELETE FROM snippets_fts WHERE docid = old.id; END;
+CREATE TRIGGER snippets_fts_au AFTER UPDATE ON snippets WHEN NOT new.encrypted BEGIN INSERT INTO snippets_fts (docid, title, content) VALUES (new.id, new.title, new.content); END;
+CREATE TRIGGER snippets_fts_ai AFTER INSERT ON snippets WHEN NOT new.encrypted BEGIN INSERT INTO snippets_fts (docid, title, content) VALUES (new.id, new.title, new.content); END;
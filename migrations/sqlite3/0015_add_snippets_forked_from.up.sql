@@ -0,0 +1,6 @@
+-- A fork records the id of the snippet it was copied from. There's no
+-- foreign key, because the original can be deleted or purged while its
+-- forks live on.
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER NULL;
+
+CREATE INDEX idx_snippets_forked_from ON snippets(forked_from);
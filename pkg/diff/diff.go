@@ -0,0 +1,256 @@
+// Package diff computes line-based differences between two texts and groups
+// them into the hunks of a unified diff.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op identifies what happened to a line.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Line is a single line of a diff. OldNum and NewNum hold the 1-based line
+// numbers in the old and new text, and are zero when the line doesn't appear
+// on that side.
+type Line struct {
+	Op     Op
+	Text   string
+	OldNum int
+	NewNum int
+}
+
+// Prefix returns the character that prefixes the line in a unified diff.
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	default:
+		return " "
+	}
+}
+
+// Hunk is a group of changed lines together with the surrounding context.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header returns the hunk's range line, like "@@ -1,4 +1,5 @@".
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Lines returns the full line-by-line difference between a and b, including
+// the lines which are the same in both.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+	ops := myers(x, y)
+
+	lines := make([]Line, 0, len(ops))
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op {
+		case Equal:
+			lines = append(lines, Line{Op: Equal, Text: x[i], OldNum: i + 1, NewNum: j + 1})
+			i++
+			j++
+		case Delete:
+			lines = append(lines, Line{Op: Delete, Text: x[i], OldNum: i + 1})
+			i++
+		case Insert:
+			lines = append(lines, Line{Op: Insert, Text: y[j], NewNum: j + 1})
+			j++
+		}
+	}
+
+	return lines
+}
+
+// Unified returns the hunks of a unified diff between a and b, with up to
+// context unchanged lines around each change. It returns nil if the texts
+// have the same lines.
+func Unified(a, b string, context int) []Hunk {
+	lines := Lines(a, b)
+
+	// Work out the hunk boundaries first: each change pulls in context lines
+	// on either side, and changes whose context overlaps share a hunk.
+	type span struct{ from, to int }
+	var spans []span
+	for i, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+
+		from, to := i-context, i+context+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		if n := len(spans); n > 0 && from <= spans[n-1].to {
+			spans[n-1].to = to
+		} else {
+			spans = append(spans, span{from, to})
+		}
+	}
+
+	var hunks []Hunk
+	oldN, newN := 0, 0
+	next := 0
+	for _, sp := range spans {
+		// Count the old and new lines that come before the hunk.
+		for ; next < sp.from; next++ {
+			oldN, newN = advance(lines[next], oldN, newN)
+		}
+
+		h := Hunk{Lines: lines[sp.from:sp.to]}
+		for _, l := range h.Lines {
+			if l.Op != Insert {
+				h.OldLines++
+			}
+			if l.Op != Delete {
+				h.NewLines++
+			}
+		}
+
+		// As in GNU diff, an empty range starts at the line before it.
+		h.OldStart, h.NewStart = oldN, newN
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+
+		hunks = append(hunks, h)
+	}
+
+	return hunks
+}
+
+// advance updates the running counts of old and new lines after l.
+func advance(l Line, oldN, newN int) (int, int) {
+	if l.Op != Insert {
+		oldN++
+	}
+	if l.Op != Delete {
+		newN++
+	}
+	return oldN, newN
+}
+
+// split breaks text into lines. A trailing newline doesn't produce an extra
+// empty line, and CRLF line endings are treated the same as LF ones.
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	return strings.Split(text, "\n")
+}
+
+// myers returns the shortest edit script that turns x into y, using the
+// greedy algorithm from Eugene Myers' "An O(ND) Difference Algorithm and Its
+// Variations".
+func myers(x, y []string) []Op {
+	n, m := len(x), len(y)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k+max] holds the furthest x reached on diagonal k. For each d we keep
+	// a copy of the diagonals -d..d, which is enough to walk back through
+	// the edit graph afterwards.
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				i = v[max+k+1]
+			} else {
+				i = v[max+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[max+k] = i
+
+			if i >= n && j >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backtrack walks back from (n, m) to (0, 0) using the saved diagonals and
+// returns the edit script in forward order.
+func backtrack(trace [][]int, n, m int) []Op {
+	var ops []Op
+	i, j := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d] }
+		k := i - j
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevI := 0
+		if d > 0 {
+			prevI = at(prevK)
+		}
+		prevJ := prevI - prevK
+		if d == 0 {
+			prevJ = 0
+		}
+
+		for i > prevI && j > prevJ {
+			ops = append(ops, Equal)
+			i--
+			j--
+		}
+
+		if d > 0 {
+			if i == prevI {
+				ops = append(ops, Insert)
+			} else {
+				ops = append(ops, Delete)
+			}
+		}
+
+		i, j = prevI, prevJ
+	}
+
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+
+	return ops
+}
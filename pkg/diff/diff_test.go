@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// render formats hunks the way `diff -u` prints them, which makes the
+// expected output in the tests easy to read.
+func render(hunks []Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		b.WriteString(h.Header() + "\n")
+		for _, l := range h.Lines {
+			b.WriteString(l.Prefix() + l.Text + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{
+			name: "Identical",
+			a:    "a\nb\nc\n",
+			b:    "a\nb\nc\n",
+			want: "",
+		},
+		{
+			name:    "Changed line",
+			a:       "a\nb\nc\n",
+			b:       "a\nB\nc\n",
+			context: 3,
+			want:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "From empty",
+			a:       "",
+			b:       "a\nb\n",
+			context: 3,
+			want:    "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "To empty",
+			a:       "a\nb\n",
+			b:       "",
+			context: 3,
+			want:    "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:    "Insert after first line without context",
+			a:       "a\nc\n",
+			b:       "a\nb\nc\n",
+			context: 0,
+			want:    "@@ -1,0 +2,1 @@\n+b\n",
+		},
+		{
+			name:    "Separate hunks",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:       "0\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+			context: 1,
+			want:    "@@ -1,2 +1,2 @@\n-1\n+0\n 2\n@@ -8,2 +8,2 @@\n 8\n-9\n+nine\n",
+		},
+		{
+			name:    "Merged hunks",
+			a:       "1\n2\n3\n4\n5\n",
+			b:       "one\n2\n3\n4\nfive\n",
+			context: 2,
+			want:    "@@ -1,5 +1,5 @@\n-1\n+one\n 2\n 3\n 4\n-5\n+five\n",
+		},
+		{
+			name:    "CRLF line endings",
+			a:       "a\r\nb\r\n",
+			b:       "a\nb\n",
+			context: 3,
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := render(Unified(tt.a, tt.b, tt.context))
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLinesMinimal(t *testing.T) {
+	// The classic example from the Myers paper: the shortest edit script
+	// between these two sequences has 5 insertions and deletions.
+	a := strings.Join(strings.Split("ABCABBA", ""), "\n")
+	b := strings.Join(strings.Split("CBABAC", ""), "\n")
+
+	edits := 0
+	var olds, news []string
+	for _, l := range Lines(a, b) {
+		if l.Op != Equal {
+			edits++
+		}
+		if l.Op != Insert {
+			olds = append(olds, l.Text)
+		}
+		if l.Op != Delete {
+			news = append(news, l.Text)
+		}
+	}
+
+	if edits != 5 {
+		t.Errorf("want 5 edits; got %d", edits)
+	}
+	if got := strings.Join(olds, "\n"); got != a {
+		t.Errorf("old side doesn't reproduce a: %q", got)
+	}
+	if got := strings.Join(news, "\n"); got != b {
+		t.Errorf("new side doesn't reproduce b: %q", got)
+	}
+}
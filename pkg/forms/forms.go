@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
 var (
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+	// durationRX matches the durations which AddDuration understands: a
+	// whole number followed by a unit.
+	durationRX = regexp.MustCompile(`^([1-9][0-9]{0,3})(m|h|d|w|mo|y)$`)
 )
 
 // Create a custom Form struct, which anonymously embeds a url.Values object
@@ -54,6 +60,16 @@ func (f *Form) MaxLength(field string, d int) {
 	}
 }
 
+// Implement a MaxBytes method to check that a specific field in the form is
+// no longer than a maximum number of bytes, for values whose limit is in
+// bytes rather than characters. If the check fails then add the appropriate
+// message to the form errors.
+func (f *Form) MaxBytes(field string, n int) {
+	if len(f.Get(field)) > n {
+		f.Errors.Add(field, fmt.Sprintf("This field is too long (maximum is %d bytes)", n))
+	}
+}
+
 // Implement a PermittedValues method to check that a specific field in the form
 // matches one of a set of specific permitted values. If the check fails then
 // add the appropriate message to the form errors.
@@ -100,3 +116,75 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 		f.Errors.Add(field, "This field is invalid")
 	}
 }
+
+// AddDuration returns t plus a duration written like "10m", "1h", "1d", "1w",
+// "1mo" or "1y", for minutes, hours, days, weeks, months and years. Days and
+// longer are calendar units, which are added with time.AddDate. If s isn't
+// a duration like that, ok is false.
+func AddDuration(t time.Time, s string) (result time.Time, ok bool) {
+	matches := durationRX.FindStringSubmatch(s)
+	if matches == nil {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	switch matches[2] {
+	case "m":
+		return t.Add(time.Duration(n) * time.Minute), true
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour), true
+	case "d":
+		return t.AddDate(0, 0, n), true
+	case "w":
+		return t.AddDate(0, 0, 7*n), true
+	case "mo":
+		return t.AddDate(0, n, 0), true
+	default:
+		return t.AddDate(n, 0, 0), true
+	}
+}
+
+// Implement a ValidDuration method to check that a specific field in the form
+// holds a duration which AddDuration understands, and which ends no more
+// than max from now. If the check fails then add the appropriate message to
+// the form errors.
+func (f *Form) ValidDuration(field string, max time.Duration) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	now := time.Now()
+	t, ok := AddDuration(now, value)
+	if !ok {
+		f.Errors.Add(field, "This field is invalid")
+		return
+	}
+	if t.Sub(now) > max {
+		f.Errors.Add(field, "This field is too far in the future")
+	}
+}
+
+// Implement a FutureTime method to check that a specific field in the form
+// holds a time in the given layout, which is in the future but no more than
+// max from now. Times without a zone are taken to be in UTC. If the check
+// fails then add the appropriate message to the form errors.
+func (f *Form) FutureTime(field, layout string, max time.Duration) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		f.Errors.Add(field, "This field is invalid")
+		return
+	}
+	now := time.Now()
+	if !t.After(now) {
+		f.Errors.Add(field, "This field must be in the future")
+	} else if t.Sub(now) > max {
+		f.Errors.Add(field, "This field is too far in the future")
+	}
+}
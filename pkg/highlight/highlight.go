@@ -0,0 +1,125 @@
+// Package highlight renders snippet content as syntax highlighted HTML using
+// chroma. The markup uses CSS classes rather than inline styles; the matching
+// stylesheet lives in ui/static/css/chroma.css.
+package highlight
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+// Style is the chroma style that chroma.css was generated from.
+const Style = "github"
+
+// PlainText is the language value for content which should never be
+// highlighted. An empty language means "detect it from the content".
+const PlainText = "text"
+
+// A Language is one of the options offered on the create snippet form. ID is
+// the value stored with the snippet, Name is what we show to users and
+// Extension is used to name downloaded files.
+type Language struct {
+	ID        string
+	Name      string
+	Extension string
+}
+
+// Languages lists the languages that can be chosen for a snippet. The IDs are
+// all names that chroma knows a lexer by.
+var Languages = []Language{
+	{PlainText, "Plain text", ".txt"},
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"css", "CSS", ".css"},
+	{"dockerfile", "Dockerfile", ".dockerfile"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"markdown", "Markdown", ".md"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+var formatter = html.New(html.WithClasses(true), html.TabWidth(4))
+
+// IDs returns the ID of every supported language, for use with
+// forms.PermittedValues.
+func IDs() []string {
+	ids := make([]string, len(Languages))
+	for i, l := range Languages {
+		ids[i] = l.ID
+	}
+	return ids
+}
+
+// Extension returns the file extension, including the leading dot, for a
+// language ID. Content in an unknown or undetected language is treated as
+// plain text.
+func Extension(id string) string {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l.Extension
+		}
+	}
+	return ".txt"
+}
+
+// Name returns the display name of a language ID, or the empty string if the
+// language isn't supported.
+func Name(id string) string {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l.Name
+		}
+	}
+	return ""
+}
+
+// HTML returns content highlighted as the given language. If language is
+// empty we try to detect it from the content. Whenever no lexer applies, or
+// highlighting fails, the content is returned HTML-escaped in a plain
+// <pre><code> block, so the result is always safe to output.
+func HTML(content, language string) template.HTML {
+	var lexer chroma.Lexer
+	switch language {
+	case PlainText:
+	case "":
+		lexer = lexers.Analyse(content)
+	default:
+		lexer = lexers.Get(language)
+	}
+
+	if lexer == nil {
+		return plain(content)
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return plain(content)
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, styles.Get(Style), iterator); err != nil {
+		return plain(content)
+	}
+
+	return template.HTML(buf.String())
+}
+
+// plain returns content escaped and wrapped in a <pre><code> block, which is
+// how the show page displayed snippets before we had highlighting.
+func plain(content string) template.HTML {
+	return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+}
@@ -0,0 +1,72 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		want     string
+		wantNot  string
+	}{
+		{
+			name:     "Go",
+			content:  "package main",
+			language: "go",
+			want:     `<span class="kn">package</span>`,
+		},
+		{
+			name:     "Plain text",
+			content:  "package main",
+			language: PlainText,
+			want:     "<pre><code>package main</code></pre>",
+		},
+		{
+			name:     "Unknown language",
+			content:  "<b>bold</b>",
+			language: "klingon",
+			want:     "<pre><code>&lt;b&gt;bold&lt;/b&gt;</code></pre>",
+		},
+		{
+			name:     "Escapes markup",
+			content:  `fmt.Println("<script>alert(1)</script>")`,
+			language: "go",
+			want:     "&lt;script&gt;",
+			wantNot:  "<script>",
+		},
+		{
+			name:     "Detected language",
+			content:  "#!/bin/bash\necho hello\n",
+			language: "",
+			want:     `<span class="nb">echo</span>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(HTML(tt.content, tt.language))
+
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("want %q to contain %q", got, tt.want)
+			}
+			if tt.wantNot != "" && strings.Contains(got, tt.wantNot) {
+				t.Errorf("want %q not to contain %q", got, tt.wantNot)
+			}
+		})
+	}
+}
+
+func TestLanguagesHaveLexers(t *testing.T) {
+	for _, l := range Languages {
+		if l.ID == PlainText {
+			continue
+		}
+		if !strings.Contains(string(HTML("x", l.ID)), `class="chroma"`) {
+			t.Errorf("no lexer for language %q", l.ID)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package markdown renders Markdown snippets to HTML which is safe to show to
+// other users.
+//
+// Safety comes from goldmark's default (non-"unsafe") mode, which leaves out
+// any raw HTML in the source, together with our own renderers for links,
+// which drop dangerous URLs like javascript: and mark every link with
+// rel="nofollow". Fenced code blocks are highlighted by the highlight package.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/petrostrak/code-snippet/pkg/highlight"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// Our node renderer is registered with a higher priority (a lower number)
+// than goldmark's default HTML renderer, which uses 1000, so its functions
+// replace the default ones for the node kinds it handles.
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&nodeRenderer{}, 100)),
+	),
+)
+
+// HTML renders content as Markdown. If rendering fails the content is shown
+// as plain text instead.
+func HTML(content string) template.HTML {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(content), &buf); err != nil {
+		return highlight.HTML(content, highlight.PlainText)
+	}
+
+	return template.HTML(buf.String())
+}
+
+// nodeRenderer overrides how goldmark renders links and fenced code blocks.
+type nodeRenderer struct{}
+
+func (r *nodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindAutoLink, r.renderAutoLink)
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+	reg.Register(ast.KindLink, r.renderLink)
+}
+
+// renderLink renders [text](url "title") links. A dangerous URL is replaced
+// by an empty href, just as goldmark does.
+func (r *nodeRenderer) renderLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Link)
+	if !entering {
+		_, _ = w.WriteString("</a>")
+		return ast.WalkContinue, nil
+	}
+
+	writeHref(w, n.Destination)
+	if n.Title != nil {
+		_, _ = w.WriteString(` title="`)
+		_, _ = w.Write(util.EscapeHTML(n.Title))
+		_ = w.WriteByte('"')
+	}
+	_ = w.WriteByte('>')
+
+	return ast.WalkContinue, nil
+}
+
+// renderAutoLink renders <url> links and, with the GFM extension, bare URLs
+// and email addresses. Unlike goldmark's own renderer, this one also checks
+// autolinks for dangerous URLs, so <javascript:alert(1)> doesn't get through.
+func (r *nodeRenderer) renderAutoLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.AutoLink)
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	url := n.URL(source)
+	if n.AutoLinkType == ast.AutoLinkEmail && !bytes.HasPrefix(bytes.ToLower(url), []byte("mailto:")) {
+		url = append([]byte("mailto:"), url...)
+	}
+
+	writeHref(w, url)
+	_ = w.WriteByte('>')
+	_, _ = w.Write(util.EscapeHTML(n.Label(source)))
+	_, _ = w.WriteString("</a>")
+
+	return ast.WalkContinue, nil
+}
+
+// writeHref writes the start of an <a> tag up to, but not including, the
+// closing '>'.
+func writeHref(w util.BufWriter, url []byte) {
+	_, _ = w.WriteString(`<a href="`)
+	if !html.IsDangerousURL(url) {
+		_, _ = w.Write(util.EscapeHTML(util.URLEscape(url, true)))
+	}
+	_, _ = w.WriteString(`" rel="nofollow"`)
+}
+
+// renderFencedCodeBlock highlights ``` blocks using the language given after
+// the opening fence. Blocks without one are shown as plain text rather than
+// guessing, since in a runbook they're as likely to be output as code.
+func (r *nodeRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.FencedCodeBlock)
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	language := string(n.Language(source))
+	if language == "" {
+		language = highlight.PlainText
+	}
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	_, _ = w.WriteString(string(highlight.HTML(code.String(), language)))
+	_ = w.WriteByte('\n')
+
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantNot []string
+	}{
+		{
+			name:    "Headings and emphasis",
+			content: "# Runbook\n\nRestart the *web* server.",
+			want:    []string{"<h1>Runbook</h1>", "<em>web</em>"},
+		},
+		{
+			name:    "Raw HTML",
+			content: "<script>alert(1)</script>\n\nText <iframe src=\"https://example.com\"></iframe>",
+			wantNot: []string{"<script", "<iframe"},
+		},
+		{
+			name:    "Links",
+			content: "[docs](https://example.com \"Docs\")",
+			want:    []string{`<a href="https://example.com" rel="nofollow" title="Docs">docs</a>`},
+		},
+		{
+			name:    "Autolinks",
+			content: "<https://example.com> and https://example.org and ops@example.com",
+			want: []string{
+				`<a href="https://example.com" rel="nofollow">`,
+				`<a href="https://example.org" rel="nofollow">`,
+				`<a href="mailto:ops@example.com" rel="nofollow">`,
+			},
+		},
+		{
+			name:    "Dangerous URLs",
+			content: "[click](javascript:alert(1)) <javascript:alert(2)>",
+			want:    []string{`<a href="" rel="nofollow">click</a>`},
+			wantNot: []string{"javascript:alert(1)", `href="javascript:`},
+		},
+		{
+			name:    "Fenced code block",
+			content: "```go\npackage main\n```",
+			want:    []string{`class="chroma"`, `<span class="kn">package</span>`},
+		},
+		{
+			name:    "Fenced code block without a language",
+			content: "```\n<b>output</b>\n```",
+			want:    []string{"<pre><code>&lt;b&gt;output&lt;/b&gt;\n</code></pre>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(HTML(tt.content))
+
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("want %q to contain %q", got, want)
+				}
+			}
+			for _, wantNot := range tt.wantNot {
+				if strings.Contains(got, wantNot) {
+					t.Errorf("want %q not to contain %q", got, wantNot)
+				}
+			}
+		})
+	}
+}
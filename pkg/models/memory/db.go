@@ -0,0 +1,84 @@
+// Package memory provides in-memory implementations of the models storage
+// interfaces. Nothing is persisted, which makes it handy for tests and for
+// running the application without a database server.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// DB plays the role that the sql.DB connection pool plays for the mysql
+// package: it holds the 'tables' that the models read from and write to.
+// A single DB can be shared by a SnippetModel, a TokenModel and a UserModel.
+type DB struct {
+	mu        sync.RWMutex
+	snippets  map[int]*models.Snippet
+	revisions map[int][]*models.Revision
+	passwords map[int][]byte
+	archive   map[int]*models.Snippet
+	stars     map[int]map[int]time.Time
+	tokens    map[int]*token
+	users     map[int]*models.User
+	lastID    map[string]int
+}
+
+// NewDB returns an empty in-memory database.
+func NewDB() *DB {
+	return &DB{
+		snippets:  map[int]*models.Snippet{},
+		revisions: map[int][]*models.Revision{},
+		passwords: map[int][]byte{},
+		archive:   map[int]*models.Snippet{},
+		stars:     map[int]map[int]time.Time{},
+		tokens:    map[int]*token{},
+		users:     map[int]*models.User{},
+		lastID:    map[string]int{},
+	}
+}
+
+// nextID emulates an AUTO_INCREMENT column for the given table. The caller
+// must hold the write lock.
+func (db *DB) nextID(table string) int {
+	db.lastID[table]++
+	return db.lastID[table]
+}
+
+// snippet returns a copy of a stored snippet with the author's name and the
+// number of stars filled in, the way the SQL backends JOIN them from their
+// own tables. Handing out copies means callers can't modify the stored
+// record. The caller must hold the read lock.
+func (db *DB) snippet(s *models.Snippet) *models.Snippet {
+	c := *s
+	c.Tags = append([]string{}, s.Tags...)
+	if u, ok := db.users[s.UserID]; ok {
+		c.Author = u.Name
+	}
+	c.Stars = len(db.stars[s.ID])
+	return &c
+}
+
+// addRevision records the current state of a snippet as its next revision.
+// The caller must hold the write lock.
+func (db *DB) addRevision(s *models.Snippet, userID int, created time.Time) {
+	db.revisions[s.ID] = append(db.revisions[s.ID], &models.Revision{
+		SnippetID: s.ID,
+		Number:    len(db.revisions[s.ID]) + 1,
+		UserID:    userID,
+		Title:     s.Title,
+		Content:   s.Content,
+		Created:   created,
+	})
+}
+
+// revision returns a copy of a stored revision with the author's name filled
+// in. The caller must hold the read lock.
+func (db *DB) revision(r *models.Revision) *models.Revision {
+	c := *r
+	if u, ok := db.users[r.UserID]; ok {
+		c.Author = u.Name
+	}
+	return &c
+}
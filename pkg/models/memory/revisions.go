@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will return every revision of a snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	stored := m.DB.revisions[snippetID]
+	revisions := make([]*models.Revision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revisions = append(revisions, m.DB.revision(stored[i]))
+	}
+
+	return revisions, nil
+}
+
+// This will return a specific revision of a snippet.
+func (m *SnippetModel) Revision(snippetID, number int) (*models.Revision, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	stored := m.DB.revisions[snippetID]
+	if number < 1 || number > len(stored) {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.DB.revision(stored[number-1]), nil
+}
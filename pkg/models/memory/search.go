@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will return up to limit public snippets which haven't expired and
+// match the search query, best matches first, skipping the first offset of
+// them. There is no index here: a snippet matches when every word of the
+// text appears somewhere in its title or content, and scores higher the
+// more often the words appear, with title matches counting double.
+func (m *SnippetModel) Search(q models.SearchQuery, limit, offset int) ([]*models.Snippet, error) {
+	words := strings.Fields(strings.ToLower(q.Text))
+
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	snippets := []*models.Snippet{}
+	scores := map[int]int{}
+
+	for _, s := range m.DB.snippets {
+		if s.Expired() || s.Visibility != models.VisibilityPublic || s.Encrypted || len(words) == 0 {
+			continue
+		}
+
+		c := m.DB.snippet(s)
+		if q.Language != "" && c.Language != q.Language {
+			continue
+		}
+		if q.Author != "" && !strings.EqualFold(c.Author, q.Author) {
+			continue
+		}
+
+		title, content := strings.ToLower(c.Title), strings.ToLower(c.Content)
+		score := 0
+		for _, w := range words {
+			n := 2*strings.Count(title, w) + strings.Count(content, w)
+			if n == 0 {
+				score = 0
+				break
+			}
+			score += n
+		}
+
+		if score > 0 {
+			scores[c.ID] = score
+			snippets = append(snippets, c)
+		}
+	}
+
+	sortNewestFirst(snippets)
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return scores[snippets[i].ID] > scores[snippets[j].ID]
+	})
+
+	if offset > len(snippets) {
+		offset = len(snippets)
+	}
+	snippets = snippets[offset:]
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+
+	return snippets, nil
+}
@@ -0,0 +1,333 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define a SnippetModel type which wraps an in-memory DB.
+type SnippetModel struct {
+	DB *DB
+}
+
+// This will insert a new snippet, owned by the given user, into the database.
+// Every snippet gets a random slug, which is how unlisted snippets are shared.
+// It expires at the given time, or never if that is zero.
+func (m *SnippetModel) Insert(userID int, title, content, language string, expires time.Time, opts models.SnippetOptions) (int, error) {
+	if opts.Visibility == "" {
+		opts.Visibility = models.VisibilityPublic
+	}
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	// The password's hash is kept apart from the snippet, in the same way
+	// that the SQL backends never select it along with the snippet.
+	var hashedPass []byte
+	if opts.Password != "" {
+		hashedPass, err = bcrypt.GenerateFromPassword([]byte(opts.Password), 12)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := time.Now().UTC()
+	s := &models.Snippet{
+		ID:         m.DB.nextID("snippets"),
+		UserID:     userID,
+		Slug:       slug,
+		Title:      title,
+		Content:    content,
+		Language:   language,
+		Visibility: opts.Visibility,
+		Created:    now,
+		Expires:    expires.UTC(),
+
+		BurnAfterReading: opts.BurnAfterReading,
+		Protected:        hashedPass != nil,
+		Encrypted:        opts.Encrypted,
+		ForkedFrom:       opts.ForkedFrom,
+	}
+	m.DB.snippets[s.ID] = s
+	if hashedPass != nil {
+		m.DB.passwords[s.ID] = hashedPass
+	}
+	m.DB.addRevision(s, userID, now)
+
+	return s.ID, nil
+}
+
+// This will return a specific snippet based on its id.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || s.Expired() {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.DB.snippet(s), nil
+}
+
+// This will return a specific snippet based on its slug.
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, s := range m.DB.snippets {
+		if s.Slug == slug && !s.Expired() {
+			return m.DB.snippet(s), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// This will return one of the user's snippets based on its slug. Unlike
+// GetBySlug, it also returns a snippet which has expired but hasn't been
+// purged yet, so that its owner can renew it.
+func (m *SnippetModel) GetOwnedBySlug(slug string, userID int) (*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, s := range m.DB.snippets {
+		if s.Slug == slug && s.UserID == userID {
+			return m.DB.snippet(s), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// This will return the 10 most recently created snippets.
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	return m.List(models.ListQuery{Limit: 10})
+}
+
+// This will return up to q.Limit public snippets which haven't expired, in
+// the order asked for by q.Sort, starting after (or before) q's cursor.
+func (m *SnippetModel) List(q models.ListQuery) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	field, desc, cursor := q.Order()
+	key := func(s *models.Snippet) models.Cursor {
+		if field == "expires" {
+			return models.Cursor{Time: s.Expires, ID: s.ID}
+		}
+		return models.Cursor{Time: s.Created, ID: s.ID}
+	}
+	// less reports whether a comes before b in the order we're listing in.
+	less := func(a, b models.Cursor) bool {
+		if !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time) == desc
+		}
+		return a.ID != b.ID && (a.ID > b.ID) == desc
+	}
+
+	snippets := []*models.Snippet{}
+	for _, s := range m.DB.snippets {
+		if s.Expired() || s.Visibility != models.VisibilityPublic || (q.Tag != "" && !hasTag(s, q.Tag)) {
+			continue
+		}
+		// Snippets which never expire have no place in a list sorted by
+		// expiry.
+		if field == "expires" && s.Expires.IsZero() {
+			continue
+		}
+		if cursor == nil || less(*cursor, key(s)) {
+			snippets = append(snippets, m.DB.snippet(s))
+		}
+	}
+	sort.Slice(snippets, func(i, j int) bool {
+		return less(key(snippets[i]), key(snippets[j]))
+	})
+
+	if len(snippets) > q.Limit {
+		snippets = snippets[:q.Limit]
+	}
+
+	if q.Before != nil {
+		models.Reverse(snippets)
+	}
+	return snippets, nil
+}
+
+// This will return every snippet created by the given user, newest first,
+// including the ones which have already expired.
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	snippets := []*models.Snippet{}
+	for _, s := range m.DB.snippets {
+		if s.UserID == userID {
+			snippets = append(snippets, m.DB.snippet(s))
+		}
+	}
+	sortNewestFirst(snippets)
+
+	return snippets, nil
+}
+
+// This will change the title and content of an existing snippet, and record
+// the result as a new revision made by the given user.
+func (m *SnippetModel) Update(id, userID int, title, content string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Title = title
+	s.Content = content
+	m.DB.addRevision(s, userID, time.Now().UTC())
+
+	return nil
+}
+
+// This will change when a snippet expires, to the given time, or never if
+// it is zero. It works on snippets which have already expired too, as long
+// as they haven't been purged, so that their owner can renew them. If there
+// is no snippet with the given id, ErrNoRecord is returned.
+func (m *SnippetModel) Extend(id int, expires time.Time) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Expires = expires.UTC()
+
+	return nil
+}
+
+// This will remove a snippet, its revisions, tags and stars from the
+// database. If there is no snippet with the given id, ErrNoRecord is
+// returned.
+func (m *SnippetModel) Delete(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.snippets[id]; !ok {
+		return models.ErrNoRecord
+	}
+	delete(m.DB.snippets, id)
+	delete(m.DB.revisions, id)
+	delete(m.DB.passwords, id)
+	delete(m.DB.stars, id)
+
+	return nil
+}
+
+// This will remove up to limit snippets which have expired, oldest expiry
+// first, and return how many it removed. If archive is true, each snippet
+// is kept in the DB's archive instead of being thrown away.
+func (m *SnippetModel) PurgeExpired(limit int, archive bool) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	expired := []*models.Snippet{}
+	for _, s := range m.DB.snippets {
+		if s.Expired() {
+			expired = append(expired, s)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].Expires.Equal(expired[j].Expires) {
+			return expired[i].ID < expired[j].ID
+		}
+		return expired[i].Expires.Before(expired[j].Expires)
+	})
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, s := range expired {
+		if archive {
+			m.DB.archive[s.ID] = s
+		}
+		delete(m.DB.snippets, s.ID)
+		delete(m.DB.revisions, s.ID)
+		delete(m.DB.passwords, s.ID)
+		delete(m.DB.stars, s.ID)
+	}
+
+	return len(expired), nil
+}
+
+// This will mark a burn-after-reading snippet as read. The check and the
+// update happen under the same lock, so when several requests race to read
+// the snippet exactly one of them succeeds, and the others get
+// ErrAlreadyRead.
+func (m *SnippetModel) MarkRead(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !s.ReadAt.IsZero() {
+		return models.ErrAlreadyRead
+	}
+	s.ReadAt = time.Now().UTC()
+
+	return nil
+}
+
+// This will check the password of a protected snippet, the same way that
+// UserModel.Authenticate checks a user's. If the password is wrong it
+// returns ErrInvalidCredentials, and if the snippet doesn't exist or isn't
+// protected it returns ErrNoRecord.
+func (m *SnippetModel) Unlock(id int, password string) error {
+	m.DB.mu.RLock()
+	hashedPassword, ok := m.DB.passwords[id]
+	m.DB.mu.RUnlock()
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return models.ErrInvalidCredentials
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// This will return the number of live snippets which were forked from the
+// given one.
+func (m *SnippetModel) CountForks(id int) (int, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	n := 0
+	for _, s := range m.DB.snippets {
+		if s.ForkedFrom == id && !s.Expired() {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// sortNewestFirst orders snippets by created DESC, falling back to the id so
+// that snippets created within the same instant come out in a stable order.
+func sortNewestFirst(snippets []*models.Snippet) {
+	sort.Slice(snippets, func(i, j int) bool {
+		if snippets[i].Created.Equal(snippets[j].Created) {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return snippets[i].Created.After(snippets[j].Created)
+	})
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will star a snippet for the given user. The stars are kept as a set
+// of users for each snippet, with when each of them starred it, so starring
+// it again does nothing.
+func (m *SnippetModel) Star(userID, snippetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.snippets[snippetID]; !ok {
+		return models.ErrNoRecord
+	}
+	if m.DB.stars[snippetID] == nil {
+		m.DB.stars[snippetID] = map[int]time.Time{}
+	}
+	if _, ok := m.DB.stars[snippetID][userID]; !ok {
+		m.DB.stars[snippetID][userID] = time.Now().UTC()
+	}
+
+	return nil
+}
+
+// This will remove the user's star from a snippet, if they had starred it.
+func (m *SnippetModel) Unstar(userID, snippetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	delete(m.DB.stars[snippetID], userID)
+
+	return nil
+}
+
+// This will report whether the user has starred a snippet.
+func (m *SnippetModel) IsStarred(userID, snippetID int) (bool, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	_, ok := m.DB.stars[snippetID][userID]
+	return ok, nil
+}
+
+// This will return the live snippets which the user has starred, most
+// recently starred first.
+func (m *SnippetModel) StarredBy(userID int) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	snippets := []*models.Snippet{}
+	starred := map[int]time.Time{}
+	for id, users := range m.DB.stars {
+		created, ok := users[userID]
+		if !ok {
+			continue
+		}
+		if s, ok := m.DB.snippets[id]; ok && !s.Expired() {
+			snippets = append(snippets, m.DB.snippet(s))
+			starred[id] = created
+		}
+	}
+	sort.Slice(snippets, func(i, j int) bool {
+		a, b := starred[snippets[i].ID], starred[snippets[j].ID]
+		if a.Equal(b) {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return a.After(b)
+	})
+
+	return snippets, nil
+}
+
+// This will return up to limit live public snippets with the most stars
+// given to them since the given time, most starred first.
+func (m *SnippetModel) MostStarred(since time.Time, limit int) ([]*models.Snippet, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	snippets := []*models.Snippet{}
+	counts := map[int]int{}
+	for id, users := range m.DB.stars {
+		s, ok := m.DB.snippets[id]
+		if !ok || s.Expired() || s.Visibility != models.VisibilityPublic {
+			continue
+		}
+		for _, created := range users {
+			if created.After(since) {
+				counts[id]++
+			}
+		}
+		if counts[id] > 0 {
+			snippets = append(snippets, m.DB.snippet(s))
+		}
+	}
+	sort.Slice(snippets, func(i, j int) bool {
+		a, b := counts[snippets[i].ID], counts[snippets[j].ID]
+		if a == b {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return a > b
+	})
+
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+	return snippets, nil
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will replace the tags of a snippet with the given ones. There's no
+// separate tags table here: each stored snippet simply holds its tags,
+// sorted by name like the SQL backends return them.
+func (m *SnippetModel) SetTags(snippetID int, tags []string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[snippetID]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	s.Tags = append([]string{}, tags...)
+	sort.Strings(s.Tags)
+
+	return nil
+}
+
+// This will return up to limit of the tags with the most live public snippets,
+// together with how many live snippets have each of them, sorted by name.
+func (m *SnippetModel) Tags(limit int) ([]*models.Tag, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, s := range m.DB.snippets {
+		if !s.Expired() && s.Visibility == models.VisibilityPublic {
+			for _, tag := range s.Tags {
+				counts[tag]++
+			}
+		}
+	}
+
+	tags := []*models.Tag{}
+	for name, count := range counts {
+		tags = append(tags, &models.Tag{Name: name, Count: count})
+	}
+
+	// Keep the most used tags, breaking ties by name so that the result
+	// doesn't depend on the map's order, then put what's left in order.
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+// hasTag reports whether s has the given tag.
+func hasTag(s *models.Snippet, tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
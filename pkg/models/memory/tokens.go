@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// token is a stored API token. models.Token doesn't carry the hash, so we
+// keep it alongside, like the hash column in the api_tokens table.
+type token struct {
+	models.Token
+	hash string
+}
+
+type TokenModel struct {
+	DB *DB
+}
+
+// This will create a new API token for the given user, which expires at the
+// given time, or never if it is zero. Only the token's hash is stored; the
+// token itself is returned so that it can be shown to the user, once.
+func (m *TokenModel) Insert(userID int, name string, expires time.Time) (string, error) {
+	plain, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t := &token{
+		Token: models.Token{
+			ID:      m.DB.nextID("api_tokens"),
+			UserID:  userID,
+			Name:    name,
+			Created: time.Now().UTC(),
+			Expires: expires,
+		},
+		hash: models.HashToken(plain),
+	}
+	m.DB.tokens[t.ID] = t
+
+	return plain, nil
+}
+
+// This will return every API token belonging to the given user, newest first.
+func (m *TokenModel) ByUser(userID int) ([]*models.Token, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	tokens := []*models.Token{}
+	for _, t := range m.DB.tokens {
+		if t.UserID == userID {
+			c := t.Token
+			tokens = append(tokens, &c)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID > tokens[j].ID
+	})
+
+	return tokens, nil
+}
+
+// This will revoke one of the given user's API tokens. If the user has no
+// token with that id, ErrNoRecord is returned.
+func (m *TokenModel) Delete(id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t, ok := m.DB.tokens[id]
+	if !ok || t.UserID != userID {
+		return models.ErrNoRecord
+	}
+	delete(m.DB.tokens, id)
+
+	return nil
+}
+
+// This will return the ID of the user that a token belongs to, and record
+// that the token has been used. Unknown and expired tokens give
+// ErrInvalidCredentials.
+func (m *TokenModel) Authenticate(plain string) (int, error) {
+	hash := models.HashToken(plain)
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, t := range m.DB.tokens {
+		if t.hash != hash {
+			continue
+		}
+		if t.Expired() {
+			return 0, models.ErrInvalidCredentials
+		}
+		t.LastUsed = time.Now().UTC()
+		return t.UserID, nil
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserModel struct {
+	DB *DB
+}
+
+// We'll use the Insert method to add a new user. Email addresses must be
+// unique, mirroring the users_uc_email constraint in the mysql schema.
+func (m *UserModel) Insert(name, email, password string) error {
+	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	u := &models.User{
+		ID:             m.DB.nextID("users"),
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPass,
+		Created:        time.Now().UTC(),
+	}
+	m.DB.users[u.ID] = u
+
+	return nil
+}
+
+// We'll use the Authenticate method to verify whether a user exists with
+// the provided email address and password. This will return the relevant
+// user ID if they do.
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.DB.mu.RLock()
+	var user *models.User
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			user = u
+			break
+		}
+	}
+	m.DB.mu.RUnlock()
+
+	if user == nil {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, models.ErrInvalidCredentials
+	} else if err != nil {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+// We'll use the Get method to fetch details for a specific user based
+// on their user ID.
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	// Like the mysql model, we don't hand the password hash back out.
+	return &models.User{
+		ID:      u.ID,
+		Name:    u.Name,
+		Email:   u.Email,
+		Created: u.Created,
+	}, nil
+}
@@ -1,7 +1,13 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -9,14 +15,102 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrInvalidCursor      = errors.New("models: invalid cursor")
+	ErrAlreadyRead        = errors.New("models: snippet has already been read")
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
+	ID         int
+	UserID     int
+	Slug       string
+	Author     string
+	Title      string
+	Content    string
+	Language   string
+	Visibility string
+	Tags       []string
+	Created    time.Time
+
+	// Expires is the zero time for snippets which never expire.
 	Expires time.Time
+
+	// A snippet which burns after reading can only be viewed once by anyone
+	// but its owner. ReadAt is when that happened, or the zero time if it
+	// hasn't been read yet.
+	BurnAfterReading bool
+	ReadAt           time.Time
+
+	// A protected snippet can only be seen by its owner and by people who
+	// know its password. The password's hash never leaves the model; use
+	// SnippetStore.Unlock to check one.
+	Protected bool
+
+	// An encrypted snippet's content was encrypted in the browser, with a
+	// key the server never sees, so Content is only ciphertext. It isn't
+	// searchable or highlighted.
+	Encrypted bool
+
+	// ForkedFrom is the ID of the snippet this one was copied from, or 0 if
+	// it isn't a fork. The original may have been deleted since.
+	ForkedFrom int
+
+	// Stars is the number of users who have starred the snippet.
+	Stars int
+}
+
+// Expired reports whether the snippet has passed its expiry time.
+func (s *Snippet) Expired() bool {
+	return !s.Expires.IsZero() && !s.Expires.After(time.Now())
+}
+
+// ExpiresSoon reports whether the snippet will expire within the next 24
+// hours.
+func (s *Snippet) ExpiresSoon() bool {
+	return !s.Expires.IsZero() && !s.Expired() && time.Until(s.Expires) < 24*time.Hour
+}
+
+// The visibility levels of a snippet. Public snippets are listed and
+// searchable. Unlisted snippets aren't, and can only be reached through
+// their slug, so only people who've been given the link can find them.
+// Private snippets can only be seen by their owner.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// SnippetOptions holds the optional settings of a new snippet, which
+// SnippetStore.Insert takes on top of its title, content and so on. The
+// zero value gives a public snippet.
+type SnippetOptions struct {
+	Visibility       string
+	BurnAfterReading bool
+	Password         string
+	Encrypted        bool
+	ForkedFrom       int
+}
+
+// NewSlug generates the random slug which a new snippet can be reached by,
+// like "Xy3_k9aQ-Lmn". Unlike the sequential ids, slugs can't be guessed.
+func NewSlug() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// A Revision is a full copy of a snippet's title and content, recorded each
+// time the snippet is created or updated. Revisions are numbered from 1 for
+// each snippet.
+type Revision struct {
+	SnippetID int
+	Number    int
+	UserID    int
+	Author    string
+	Title     string
+	Content   string
+	Created   time.Time
 }
 
 // Define a new User type.
@@ -27,3 +121,204 @@ type User struct {
 	HashedPassword []byte
 	Created        time.Time
 }
+
+// The orders in which SnippetStore.List can return snippets. SortNewest is
+// the default.
+const (
+	SortNewest   = "newest"
+	SortOldest   = "oldest"
+	SortExpiring = "expiring"
+)
+
+// A Cursor marks a snippet's position in a sorted list of snippets, by the
+// time the list is sorted on (when the snippet was created, or when it
+// expires) and the snippet's ID, which breaks ties. Unlike an offset, a
+// cursor stays valid when snippets are added to or removed from the list.
+type Cursor struct {
+	Time time.Time
+	ID   int
+}
+
+// NewCursor returns the position of s in a list sorted by sort.
+func NewCursor(s *Snippet, sort string) Cursor {
+	if sort == SortExpiring {
+		return Cursor{Time: s.Expires, ID: s.ID}
+	}
+	return Cursor{Time: s.Created, ID: s.ID}
+}
+
+// Encode returns the cursor as an opaque, URL-safe string.
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.Time.UnixNano(), 10) + "." + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a string made by Cursor.Encode. Anything else gives
+// ErrInvalidCursor.
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Time: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// A ListQuery describes one page of snippets for SnippetStore.List. At most
+// one of After and Before should be set: After asks for the snippets which
+// follow that position in the list, and Before for the ones which precede
+// it. Either way, the snippets are returned in Sort order. If Tag is set,
+// only the snippets with that tag are listed. Snippets which never expire
+// are left out when sorting by SortExpiring.
+type ListQuery struct {
+	Sort   string
+	Tag    string
+	After  *Cursor
+	Before *Cursor
+	Limit  int
+}
+
+// Order tells a storage backend how to run q: which field to sort on
+// ("created" or "expires"), in which direction, and which cursor, if any,
+// the results start from. A Before query is run in the opposite direction,
+// starting from the Before cursor, and the backend then reverses the
+// results it gets.
+func (q ListQuery) Order() (field string, desc bool, cursor *Cursor) {
+	switch q.Sort {
+	case SortOldest:
+		field, desc = "created", false
+	case SortExpiring:
+		field, desc = "expires", false
+	default:
+		field, desc = "created", true
+	}
+
+	if q.Before != nil {
+		return field, !desc, q.Before
+	}
+	return field, desc, q.After
+}
+
+// Reverse reverses a slice of snippets in place.
+func Reverse(snippets []*Snippet) {
+	for i, j := 0, len(snippets)-1; i < j; i, j = i+1, j-1 {
+		snippets[i], snippets[j] = snippets[j], snippets[i]
+	}
+}
+
+// A Tag is a tag's name together with the number of live snippets which
+// have it, for the tag cloud.
+type Tag struct {
+	Name  string
+	Count int
+}
+
+// A SearchQuery describes a full-text search for snippets. Text is matched
+// against the title and content of each snippet, and the results can be
+// narrowed down to one Language or one Author (a user's name). Empty filters
+// match everything.
+type SearchQuery struct {
+	Text     string
+	Language string
+	Author   string
+}
+
+// A Token is a personal API token which lets a user's scripts use the JSON API
+// on their behalf. Only a hash of the token itself is stored, so the Token
+// doesn't hold it. Expires and LastUsed are zero when the token never
+// expires, or hasn't been used yet.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Created  time.Time
+	Expires  time.Time
+	LastUsed time.Time
+}
+
+// Expired reports whether the token has passed its expiry time.
+func (t *Token) Expired() bool {
+	return !t.Expires.IsZero() && !t.Expires.After(time.Now())
+}
+
+// NewToken generates a new random API token, which is what the user gives to
+// their scripts. The storage backends only keep its HashToken.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of a token. Tokens are long
+// and random, so unlike passwords they don't need a slow hash like bcrypt,
+// and a fast one lets us look tokens up by their hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// The SnippetStore interface describes the methods that our handlers need
+// from a snippet storage backend. The SnippetModel in each of the mysql,
+// postgres, sqlite and memory packages satisfies it.
+//
+// Search never returns encrypted snippets. Their content is nothing but
+// ciphertext, so every backend keeps it out of its search index.
+type SnippetStore interface {
+	Insert(userID int, title, content, language string, expires time.Time, opts SnippetOptions) (int, error)
+	Get(id int) (*Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	GetOwnedBySlug(slug string, userID int) (*Snippet, error)
+	Latest() ([]*Snippet, error)
+	List(q ListQuery) ([]*Snippet, error)
+	Search(q SearchQuery, limit, offset int) ([]*Snippet, error)
+	ByUser(userID int) ([]*Snippet, error)
+	Update(id, userID int, title, content string) error
+	Extend(id int, expires time.Time) error
+	Delete(id int) error
+	PurgeExpired(limit int, archive bool) (int, error)
+	MarkRead(id int) error
+	Unlock(id int, password string) error
+	CountForks(id int) (int, error)
+	Revisions(snippetID int) ([]*Revision, error)
+	Revision(snippetID, number int) (*Revision, error)
+	SetTags(snippetID int, tags []string) error
+	Tags(limit int) ([]*Tag, error)
+	Star(userID, snippetID int) error
+	Unstar(userID, snippetID int) error
+	IsStarred(userID, snippetID int) (bool, error)
+	StarredBy(userID int) ([]*Snippet, error)
+	MostStarred(since time.Time, limit int) ([]*Snippet, error)
+}
+
+// The UserStore interface describes the methods that our handlers need from
+// a user storage backend.
+type UserStore interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Get(id int) (*User, error)
+}
+
+// The TokenStore interface describes the methods that our handlers need from
+// an API token storage backend.
+type TokenStore interface {
+	Insert(userID int, name string, expires time.Time) (string, error)
+	ByUser(userID int) ([]*Token, error)
+	Delete(id, userID int) error
+	Authenticate(token string) (int, error)
+}
@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// The revisionColumns and revisionTables constants are shared by the queries
+// which return revisions, so that they can be read by scanRevision().
+const (
+	revisionColumns = `r.snippet_id, r.revision, COALESCE(r.user_id, 0), COALESCE(u.name, ''), r.title, r.content, r.created`
+	revisionTables  = `snippet_revisions r LEFT JOIN users u ON u.id = r.user_id`
+)
+
+// This will return every revision of a snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	stmt := `SELECT ` + revisionColumns + ` FROM ` + revisionTables + `
+			 WHERE r.snippet_id = ? ORDER BY r.revision DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// This will return a specific revision of a snippet.
+func (m *SnippetModel) Revision(snippetID, number int) (*models.Revision, error) {
+	stmt := `SELECT ` + revisionColumns + ` FROM ` + revisionTables + `
+			 WHERE r.snippet_id = ? AND r.revision = ?`
+
+	r, err := scanRevision(m.DB.QueryRow(stmt, snippetID, number))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// scanRevision reads the columns listed in revisionColumns into a new Revision.
+func scanRevision(row scanner) (*models.Revision, error) {
+	r := &models.Revision{}
+
+	err := row.Scan(
+		&r.SnippetID,
+		&r.Number,
+		&r.UserID,
+		&r.Author,
+		&r.Title,
+		&r.Content,
+		&r.Created,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
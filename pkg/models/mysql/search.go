@@ -0,0 +1,34 @@
+package mysql
+
+import (
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will return up to limit public snippets which haven't expired and
+// match the search query, best matches first, skipping the first offset of
+// them. The text is matched against the snippets_ft_search FULLTEXT index in
+// natural language mode, which accepts any input without needing to be
+// escaped. The index is on the search_title and search_content columns,
+// which are copies of the title and content left empty for encrypted
+// snippets.
+func (m *SnippetModel) Search(q models.SearchQuery, limit, offset int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE MATCH(s.search_title, s.search_content) AGAINST(? IN NATURAL LANGUAGE MODE)
+			 AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.visibility = 'public'`
+	args := []interface{}{q.Text}
+
+	if q.Language != "" {
+		stmt += ` AND s.language = ?`
+		args = append(args, q.Language)
+	}
+	if q.Author != "" {
+		stmt += ` AND u.name = ?`
+		args = append(args, q.Author)
+	}
+
+	stmt += ` ORDER BY MATCH(s.search_title, s.search_content) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, s.created DESC, s.id DESC
+			  LIMIT ? OFFSET ?`
+	args = append(args, q.Text, limit, offset)
+
+	return m.query(stmt, args...)
+}
@@ -0,0 +1,121 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// Define a TokenModel type which wraps a sql.DB connection pool.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// This will create a new API token for the given user, which expires at the
+// given time, or never if it is zero. Only the token's hash is stored; the
+// token itself is returned so that it can be shown to the user, once.
+func (m *TokenModel) Insert(userID int, name string, expires time.Time) (string, error) {
+	token, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, hash, created, expires)
+			 VALUES(?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	expiresAt := sql.NullTime{Time: expires.UTC(), Valid: !expires.IsZero()}
+	if _, err := m.DB.Exec(stmt, userID, name, models.HashToken(token), expiresAt); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// This will return every API token belonging to the given user, newest first.
+func (m *TokenModel) ByUser(userID int) ([]*models.Token, error) {
+	stmt := `SELECT id, user_id, name, created, expires, last_used FROM api_tokens
+			 WHERE user_id = ? ORDER BY created DESC, id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// This will revoke one of the given user's API tokens. If the user has no
+// token with that id, ErrNoRecord is returned.
+func (m *TokenModel) Delete(id, userID int) error {
+	rs, err := m.DB.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will return the ID of the user that a token belongs to, and record
+// that the token has been used. Unknown and expired tokens give
+// ErrInvalidCredentials.
+func (m *TokenModel) Authenticate(token string) (int, error) {
+	var id, userID int
+	var expires sql.NullTime
+
+	stmt := `SELECT id, user_id, expires FROM api_tokens WHERE hash = ?`
+	err := m.DB.QueryRow(stmt, models.HashToken(token)).Scan(&id, &userID, &expires)
+	if err == sql.ErrNoRows {
+		return 0, models.ErrInvalidCredentials
+	} else if err != nil {
+		return 0, err
+	}
+
+	if t := (&models.Token{Expires: expires.Time}); t.Expired() {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	if _, err := m.DB.Exec(`UPDATE api_tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// scanToken reads the columns selected by ByUser() into a new Token.
+func scanToken(row scanner) (*models.Token, error) {
+	t := &models.Token{}
+	var expires, lastUsed sql.NullTime
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Created, &expires, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Expires = expires.Time
+	t.LastUsed = lastUsed.Time
+
+	return t, nil
+}
@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will return up to limit public snippets which haven't expired and
+// match the search query, best matches first, skipping the first offset of
+// them. The text is matched against the generated search column with
+// websearch_to_tsquery(), which accepts any input, including quoted phrases
+// and -excluded words, without raising a syntax error.
+func (m *SnippetModel) Search(q models.SearchQuery, limit, offset int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE s.search @@ websearch_to_tsquery('simple', $1) AND (s.expires IS NULL OR s.expires > NOW()) AND s.visibility = 'public' AND NOT s.encrypted`
+	args := []interface{}{q.Text}
+
+	if q.Language != "" {
+		args = append(args, q.Language)
+		stmt += fmt.Sprintf(` AND s.language = $%d`, len(args))
+	}
+	if q.Author != "" {
+		args = append(args, q.Author)
+		stmt += fmt.Sprintf(` AND LOWER(u.name) = LOWER($%d)`, len(args))
+	}
+
+	args = append(args, limit, offset)
+	stmt += fmt.Sprintf(` ORDER BY ts_rank(s.search, websearch_to_tsquery('simple', $1)) DESC, s.created DESC, s.id DESC
+			  LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	return m.query(stmt, args...)
+}
@@ -0,0 +1,474 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/petrostrak/code-snippet/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The snippetColumns and snippetTables constants are shared by every query
+// which returns snippets, so that they can all be read by scanSnippet().
+const (
+	snippetColumns = `s.id, COALESCE(s.user_id, 0), s.slug, COALESCE(u.name, ''), s.title, s.content, s.language, s.visibility, s.created, s.expires, s.burn_after_reading, s.read_at, s.hashed_password IS NOT NULL, s.encrypted, COALESCE(s.forked_from, 0), (SELECT COUNT(*) FROM stars WHERE snippet_id = s.id)`
+	snippetTables  = `snippets s LEFT JOIN users u ON u.id = s.user_id`
+)
+
+// Define a SnippetModel type which wraps a sql.DB connection pool opened
+// with the postgres driver.
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+// This will insert a new snippet, owned by the given user, into the database.
+// Every snippet gets a random slug, which is how unlisted snippets are shared.
+// It expires at the given time, or never if that is zero. PostgreSQL doesn't
+// support LastInsertId(), so we ask for the new id with a RETURNING clause.
+// The snippet's first revision is recorded in the same transaction.
+func (m *SnippetModel) Insert(userID int, title, content, language string, expires time.Time, opts models.SnippetOptions) (int, error) {
+	if opts.Visibility == "" {
+		opts.Visibility = models.VisibilityPublic
+	}
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	// Snippets without a password have a NULL hashed_password. The others
+	// get a bcrypt hash, just like a user's password.
+	var hashedPass sql.NullString
+	if opts.Password != "" {
+		h, err := bcrypt.GenerateFromPassword([]byte(opts.Password), 12)
+		if err != nil {
+			return 0, err
+		}
+		hashedPass = sql.NullString{String: string(h), Valid: true}
+	}
+
+	// Snippets which aren't forks have a NULL forked_from, and snippets
+	// which never expire have a NULL expiry time.
+	forkedFrom := sql.NullInt64{Int64: int64(opts.ForkedFrom), Valid: opts.ForkedFrom != 0}
+	expiresAt := sql.NullTime{Time: expires.UTC(), Valid: !expires.IsZero()}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (user_id, slug, title, content, language, visibility, burn_after_reading, hashed_password, encrypted, forked_from, created, expires)
+			 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), $11)
+			 RETURNING id`
+
+	var id int
+	if err := tx.QueryRow(stmt, userID, slug, title, content, language, opts.Visibility, opts.BurnAfterReading, hashedPass, opts.Encrypted, forkedFrom, expiresAt).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+			SELECT id, 1, user_id, title, content, created FROM snippets WHERE id = $1`
+
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// This will return a specific snippet based on its id.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	return m.get(`s.id = $1`, id)
+}
+
+// This will return a specific snippet based on its slug.
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	return m.get(`s.slug = $1`, slug)
+}
+
+// This will return one of the user's snippets based on its slug. Unlike
+// GetBySlug, it also returns a snippet which has expired but hasn't been
+// purged yet, so that its owner can renew it.
+func (m *SnippetModel) GetOwnedBySlug(slug string, userID int) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE s.slug = $1 AND s.user_id = $2`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug, userID))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	if s.Tags, err = m.snippetTags(s.ID); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// get returns the live snippet which matches the condition, which compares
+// a column with the single argument arg.
+func (m *SnippetModel) get(cond string, arg interface{}) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE (s.expires IS NULL OR s.expires > NOW()) AND ` + cond
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, arg))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Fill in the snippet's tags, which live in their own table.
+	if s.Tags, err = m.snippetTags(s.ID); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// This will return the 10 most recently created snippets.
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	return m.List(models.ListQuery{Limit: 10})
+}
+
+// This will return up to q.Limit public snippets which haven't expired, in
+// the order asked for by q.Sort. The created (or expires) time and the id
+// together decide where each snippet falls relative to the cursor, so
+// snippets created at the same moment are neither skipped nor repeated
+// between pages.
+func (m *SnippetModel) List(q models.ListQuery) ([]*models.Snippet, error) {
+	field, desc, cursor := q.Order()
+	column := "s." + field
+	cmp, dir := ">", "ASC"
+	if desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE (s.expires IS NULL OR s.expires > NOW()) AND s.visibility = 'public'`
+
+	// Snippets which never expire have no place in a list sorted by expiry.
+	if field == "expires" {
+		stmt += ` AND s.expires IS NOT NULL`
+	}
+
+	args := []interface{}{}
+	if q.Tag != "" {
+		args = append(args, q.Tag)
+		stmt += fmt.Sprintf(` AND s.id IN (SELECT st.snippet_id FROM snippet_tags st JOIN tags t ON t.id = st.tag_id WHERE t.name = $%d)`, len(args))
+	}
+	if cursor != nil {
+		args = append(args, cursor.Time, cursor.ID)
+		stmt += fmt.Sprintf(` AND (%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND s.id %[2]s $%[4]d))`, column, cmp, len(args)-1, len(args))
+	}
+	args = append(args, q.Limit)
+	stmt += fmt.Sprintf(` ORDER BY %[1]s %[2]s, s.id %[2]s LIMIT $%[3]d`, column, dir, len(args))
+
+	snippets, err := m.query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Before != nil {
+		models.Reverse(snippets)
+	}
+	return snippets, nil
+}
+
+// This will return every snippet created by the given user, newest first,
+// including the ones which have already expired.
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE s.user_id = $1 ORDER BY s.created DESC`
+
+	return m.query(stmt, userID)
+}
+
+// This will change the title and content of an existing snippet, and record
+// the result as a new revision made by the given user.
+func (m *SnippetModel) Update(id, userID int, title, content string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = $1, content = $2 WHERE id = $3`
+	if _, err := tx.Exec(stmt, title, content, id); err != nil {
+		return err
+	}
+
+	var number int
+	stmt = `SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = $1`
+	if err := tx.QueryRow(stmt, id).Scan(&number); err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+			VALUES($1, $2, $3, $4, $5, NOW())`
+	if _, err := tx.Exec(stmt, id, number, userID, title, content); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// This will change when a snippet expires, to the given time, or never if
+// it is zero. It works on snippets which have already expired too, as long
+// as they haven't been purged, so that their owner can renew them. If there
+// is no snippet with the given id, ErrNoRecord is returned.
+func (m *SnippetModel) Extend(id int, expires time.Time) error {
+	expiresAt := sql.NullTime{Time: expires.UTC(), Valid: !expires.IsZero()}
+
+	rs, err := m.DB.Exec(`UPDATE snippets SET expires = $1 WHERE id = $2`, expiresAt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will remove a snippet, its revisions, tags and stars from the
+// database. If there is no snippet with the given id, ErrNoRecord is
+// returned.
+func (m *SnippetModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = $1`, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = $1`, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM stars WHERE snippet_id = $1`, id); err != nil {
+		return err
+	}
+
+	rs, err := tx.Exec(`DELETE FROM snippets WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return tx.Commit()
+}
+
+// This will remove up to limit snippets which have expired, oldest expiry
+// first, along with their revisions, tags and stars, and return how many it
+// removed. If archive is true, each snippet is copied to the
+// snippets_archive table before it's deleted. The batch is handled in a
+// single transaction.
+func (m *SnippetModel) PurgeExpired(limit int, archive bool) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the expired rows, so that if another instance of the application
+	// is sweeping at the same time it waits for us, and then skips them.
+	stmt := `SELECT id FROM snippets WHERE expires <= NOW() ORDER BY expires, id LIMIT $1 FOR UPDATE`
+	rows, err := tx.Query(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if archive {
+		stmt = `INSERT INTO snippets_archive (id, user_id, slug, title, content, language, visibility, encrypted, created, expires, archived)
+				SELECT id, user_id, slug, title, content, language, visibility, encrypted, created, expires, NOW()
+				FROM snippets WHERE id = ANY($1)`
+		if _, err := tx.Exec(stmt, pq.Array(ids)); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM stars WHERE snippet_id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+
+	rs, err := tx.Exec(`DELETE FROM snippets WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), tx.Commit()
+}
+
+// This will mark a burn-after-reading snippet as read. The read_at column
+// is only set if it's still NULL, so when several requests race to read the
+// snippet exactly one of them succeeds, and the others get ErrAlreadyRead.
+func (m *SnippetModel) MarkRead(id int) error {
+	stmt := `UPDATE snippets SET read_at = NOW() WHERE id = $1 AND read_at IS NULL`
+
+	rs, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrAlreadyRead
+	}
+
+	return nil
+}
+
+// This will check the password of a protected snippet, the same way that
+// UserModel.Authenticate checks a user's. If the password is wrong it
+// returns ErrInvalidCredentials, and if the snippet doesn't exist or isn't
+// protected it returns ErrNoRecord.
+func (m *SnippetModel) Unlock(id int, password string) error {
+	var hashedPassword []byte
+	row := m.DB.QueryRow("SELECT hashed_password FROM snippets WHERE id = $1 AND hashed_password IS NOT NULL", id)
+	err := row.Scan(&hashedPassword)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return models.ErrInvalidCredentials
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// This will return the number of live snippets which were forked from the
+// given one.
+func (m *SnippetModel) CountForks(id int) (int, error) {
+	stmt := `SELECT COUNT(*) FROM snippets WHERE forked_from = $1 AND (expires IS NULL OR expires > NOW())`
+
+	var n int
+	if err := m.DB.QueryRow(stmt, id).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// query runs a SELECT statement built from snippetColumns and returns the
+// resulting snippets.
+func (m *SnippetModel) query(stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// The scanner interface is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSnippet reads the columns listed in snippetColumns into a new Snippet.
+func scanSnippet(row scanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var expires, readAt sql.NullTime
+
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.Slug,
+		&s.Author,
+		&s.Title,
+		&s.Content,
+		&s.Language,
+		&s.Visibility,
+		&s.Created,
+		&expires,
+		&s.BurnAfterReading,
+		&readAt,
+		&s.Protected,
+		&s.Encrypted,
+		&s.ForkedFrom,
+		&s.Stars,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.Expires = expires.Time
+	s.ReadAt = readAt.Time
+
+	return s, nil
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will star a snippet for the given user. Each user can only star a
+// snippet once, so starring it again does nothing.
+func (m *SnippetModel) Star(userID, snippetID int) error {
+	stmt := `INSERT INTO stars (snippet_id, user_id, created) VALUES($1, $2, NOW())
+			 ON CONFLICT (snippet_id, user_id) DO NOTHING`
+
+	_, err := m.DB.Exec(stmt, snippetID, userID)
+	return err
+}
+
+// This will remove the user's star from a snippet, if they had starred it.
+func (m *SnippetModel) Unstar(userID, snippetID int) error {
+	_, err := m.DB.Exec(`DELETE FROM stars WHERE snippet_id = $1 AND user_id = $2`, snippetID, userID)
+	return err
+}
+
+// This will report whether the user has starred a snippet.
+func (m *SnippetModel) IsStarred(userID, snippetID int) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT 1 FROM stars WHERE snippet_id = $1 AND user_id = $2)`
+
+	var starred bool
+	err := m.DB.QueryRow(stmt, snippetID, userID).Scan(&starred)
+	return starred, err
+}
+
+// This will return the live snippets which the user has starred, most
+// recently starred first.
+func (m *SnippetModel) StarredBy(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 JOIN stars st ON st.snippet_id = s.id
+			 WHERE st.user_id = $1 AND (s.expires IS NULL OR s.expires > NOW())
+			 ORDER BY st.created DESC, s.id DESC`
+
+	return m.query(stmt, userID)
+}
+
+// This will return up to limit live public snippets with the most stars
+// given to them since the given time, most starred first.
+func (m *SnippetModel) MostStarred(since time.Time, limit int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 JOIN (SELECT snippet_id, COUNT(*) AS n FROM stars WHERE created > $1 GROUP BY snippet_id) w ON w.snippet_id = s.id
+			 WHERE (s.expires IS NULL OR s.expires > NOW()) AND s.visibility = 'public'
+			 ORDER BY w.n DESC, s.id DESC LIMIT $2`
+
+	return m.query(stmt, since, limit)
+}
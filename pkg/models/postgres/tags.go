@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"sort"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will replace the tags of a snippet with the given ones. Tags are
+// shared between snippets, so each one is only added to the tags table the
+// first time it's used.
+func (m *SnippetModel) SetTags(snippetID int, tags []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = $1`, snippetID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT INTO tags (name) VALUES($1) ON CONFLICT (name) DO NOTHING`, tag); err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO snippet_tags (snippet_id, tag_id) SELECT $1::INTEGER, id FROM tags WHERE name = $2`
+		if _, err := tx.Exec(stmt, snippetID, tag); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// This will return up to limit of the tags with the most live public snippets,
+// together with how many live snippets have each of them. They're sorted by
+// name, which is the order the tag cloud shows them in.
+func (m *SnippetModel) Tags(limit int) ([]*models.Tag, error) {
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+			 JOIN snippet_tags st ON st.tag_id = t.id
+			 JOIN snippets s ON s.id = st.snippet_id
+			 WHERE (s.expires IS NULL OR s.expires > NOW()) AND s.visibility = 'public'
+			 GROUP BY t.name ORDER BY COUNT(*) DESC, t.name LIMIT $1`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		t := &models.Tag{}
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// snippetTags returns the names of a snippet's tags, in alphabetical order.
+func (m *SnippetModel) snippetTags(snippetID int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t JOIN snippet_tags st ON st.tag_id = t.id
+			 WHERE st.snippet_id = $1 ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
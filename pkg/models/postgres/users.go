@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/petrostrak/code-snippet/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// uniqueViolation is the SQLSTATE code PostgreSQL uses when a unique
+// constraint is violated.
+const uniqueViolation = "23505"
+
+type UserModel struct {
+	DB *sql.DB
+}
+
+// We'll use the Insert method to add a new record to the users table.
+func (m *UserModel) Insert(name, email, password string) error {
+	// Create a bcrypt hash of the plain-text password.
+	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created)
+			 VALUES($1, $2, $3, NOW())`
+
+	// If this returns an error, we try to type assert it to a *pq.Error so we
+	// can check whether it is a unique violation on our users_uc_email
+	// constraint. If it is, we return an ErrDuplicateEmail error.
+	_, err = m.DB.Exec(stmt, name, email, string(hashedPass))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == uniqueViolation && pqErr.Constraint == "users_uc_email" {
+				return models.ErrDuplicateEmail
+			}
+		}
+	}
+
+	return err
+}
+
+// We'll use the Authenticate method to verify whether a user exists with
+// the provided email address and password. This will return the relevant
+// user ID if they do.
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	var id int
+	var hashedPassword []byte
+	row := m.DB.QueryRow("SELECT id, hashed_password FROM users WHERE email = $1", email)
+	err := row.Scan(
+		&id,
+		&hashedPassword,
+	)
+	if err == sql.ErrNoRows {
+		return 0, models.ErrInvalidCredentials
+	} else if err != nil {
+		return 0, err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, models.ErrInvalidCredentials
+	} else if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// We'll use the Get method to fetch details for a specific user based
+// on their user ID.
+func (m *UserModel) Get(id int) (*models.User, error) {
+	s := &models.User{}
+
+	stmt := `SELECT id, name, email, created FROM users WHERE id = $1`
+	err := m.DB.QueryRow(stmt, id).Scan(
+		&s.ID,
+		&s.Name,
+		&s.Email,
+		&s.Created,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
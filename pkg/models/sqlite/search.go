@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will return up to limit public snippets which haven't expired and
+// match the search query, newest first, skipping the first offset of them.
+// The text is matched using the snippets_fts full-text index. FTS4 has no
+// built-in ranking function, so unlike the other backends the results
+// aren't ordered by relevance.
+func (m *SnippetModel) Search(q models.SearchQuery, limit, offset int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 JOIN snippets_fts ON snippets_fts.docid = s.id
+			 WHERE snippets_fts MATCH ? AND (s.expires IS NULL OR s.expires > datetime('now')) AND s.visibility = 'public' AND NOT s.encrypted`
+	args := []interface{}{matchQuery(q.Text)}
+
+	if q.Language != "" {
+		stmt += ` AND s.language = ?`
+		args = append(args, q.Language)
+	}
+	if q.Author != "" {
+		stmt += ` AND LOWER(u.name) = LOWER(?)`
+		args = append(args, q.Author)
+	}
+
+	stmt += ` ORDER BY s.created DESC, s.id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	return m.query(stmt, args...)
+}
+
+// matchQuery turns free text into an FTS4 MATCH expression. Each word is
+// quoted, so that characters which mean something in the query syntax (like
+// '-', '*' or '"') are searched for rather than causing a syntax error, and
+// the words are implicitly ANDed together.
+func matchQuery(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
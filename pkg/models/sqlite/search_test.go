@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestSnippetModelSearch(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	users := UserModel{db}
+
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := users.Insert(name, name+"@example.com", "validPa$$word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inserts := []struct {
+		userID                   int
+		title, content, language string
+	}{
+		{1, "Reload nginx", "sudo systemctl reload nginx", "bash"},
+		{1, "Nginx config", "server { listen 80; }", ""},
+		{2, "Docker cleanup", "docker system prune -a", "bash"},
+		{2, "Expired nginx", "nginx -t", "bash"},
+	}
+	for _, i := range inserts {
+		if _, err := m.Insert(i.userID, i.title, i.content, i.language, time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("UPDATE snippets SET expires = datetime('now', '-1 minute') WHERE id = 4"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The index must follow updates and deletes made to the snippets table.
+	if err := m.Update(3, 2, "Docker cleanup", "docker image prune"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		query   models.SearchQuery
+		wantIDs []int
+	}{
+		{"Title or content", models.SearchQuery{Text: "nginx"}, []int{2, 1}},
+		{"All words", models.SearchQuery{Text: "reload nginx"}, []int{1}},
+		{"Language", models.SearchQuery{Text: "nginx", Language: "bash"}, []int{1}},
+		{"Author", models.SearchQuery{Text: "nginx", Author: "alice"}, []int{2, 1}},
+		{"Other author", models.SearchQuery{Text: "nginx", Author: "Bob"}, []int{}},
+		{"Updated content", models.SearchQuery{Text: "image"}, []int{3}},
+		{"Replaced content", models.SearchQuery{Text: "system"}, []int{}},
+		{"Query syntax", models.SearchQuery{Text: `nginx -a "OR *`}, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.Search(tt.query, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []int{}
+			for _, s := range snippets {
+				ids = append(ids, s.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("want %v; got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+
+	if err := m.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	snippets, err := m.Search(models.SearchQuery{Text: "reload"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 0 {
+		t.Errorf("want deleted snippets to be removed from the index")
+	}
+}
+
+func TestSnippetModelSearchEncrypted(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	id, err := m.Insert(1, "Needle", "bmVlZGxl", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{Encrypted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Encrypted snippets never make it into the full-text index, even after
+	// they're updated, so they can't be found.
+	if err := m.Update(id, 1, "Needle", "bmVlZGxlcw"); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM snippets_fts WHERE snippets_fts MATCH 'needle'").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want no encrypted snippets in the index; got %d", n)
+	}
+
+	snippets, err := m.Search(models.SearchQuery{Text: "needle"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 0 {
+		t.Errorf("want no results; got %d", len(snippets))
+	}
+
+	if err := m.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+}
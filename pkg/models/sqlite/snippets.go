@@ -0,0 +1,481 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The snippetColumns and snippetTables constants are shared by every query
+// which returns snippets, so that they can all be read by scanSnippet().
+const (
+	snippetColumns = `s.id, COALESCE(s.user_id, 0), s.slug, COALESCE(u.name, ''), s.title, s.content, s.language, s.visibility, s.created, s.expires, s.burn_after_reading, s.read_at, s.hashed_password IS NOT NULL, s.encrypted, COALESCE(s.forked_from, 0), (SELECT COUNT(*) FROM stars WHERE snippet_id = s.id)`
+	snippetTables  = `snippets s LEFT JOIN users u ON u.id = s.user_id`
+)
+
+// timeFormat is the layout of the times which datetime('now') returns.
+const timeFormat = "2006-01-02 15:04:05"
+
+// Define a SnippetModel type which wraps a sql.DB connection pool opened
+// with the sqlite3 driver.
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+// This will insert a new snippet, owned by the given user, into the database.
+// Every snippet gets a random slug, which is how unlisted snippets are shared.
+// It expires at the given time, or never if that is zero. SQLite stores times
+// as text and compares them as strings, so the expiry time is formatted the
+// same way datetime() formats them. The snippet's first revision is recorded
+// in the same transaction.
+func (m *SnippetModel) Insert(userID int, title, content, language string, expires time.Time, opts models.SnippetOptions) (int, error) {
+	if opts.Visibility == "" {
+		opts.Visibility = models.VisibilityPublic
+	}
+	slug, err := models.NewSlug()
+	if err != nil {
+		return 0, err
+	}
+
+	// Snippets without a password have a NULL hashed_password. The others
+	// get a bcrypt hash, just like a user's password.
+	var hashedPass sql.NullString
+	if opts.Password != "" {
+		h, err := bcrypt.GenerateFromPassword([]byte(opts.Password), 12)
+		if err != nil {
+			return 0, err
+		}
+		hashedPass = sql.NullString{String: string(h), Valid: true}
+	}
+
+	// Snippets which aren't forks have a NULL forked_from, and snippets
+	// which never expire have a NULL expiry time.
+	forkedFrom := sql.NullInt64{Int64: int64(opts.ForkedFrom), Valid: opts.ForkedFrom != 0}
+	expiresAt := sql.NullString{String: expires.UTC().Format(timeFormat), Valid: !expires.IsZero()}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (user_id, slug, title, content, language, visibility, burn_after_reading, hashed_password, encrypted, forked_from, created, expires)
+			 VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), ?)`
+
+	rs, err := tx.Exec(stmt, userID, slug, title, content, language, opts.Visibility, opts.BurnAfterReading, hashedPass, opts.Encrypted, forkedFrom, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := rs.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+			SELECT id, 1, user_id, title, content, created FROM snippets WHERE id = ?`
+
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// This will return a specific snippet based on its id.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	return m.get(`s.id = ?`, id)
+}
+
+// This will return a specific snippet based on its slug.
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	return m.get(`s.slug = ?`, slug)
+}
+
+// This will return one of the user's snippets based on its slug. Unlike
+// GetBySlug, it also returns a snippet which has expired but hasn't been
+// purged yet, so that its owner can renew it.
+func (m *SnippetModel) GetOwnedBySlug(slug string, userID int) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE s.slug = ? AND s.user_id = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug, userID))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	if s.Tags, err = m.snippetTags(s.ID); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// get returns the live snippet which matches the condition, which compares
+// a column with the single argument arg.
+func (m *SnippetModel) get(cond string, arg interface{}) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE (s.expires IS NULL OR s.expires > datetime('now')) AND ` + cond
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, arg))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Fill in the snippet's tags, which live in their own table.
+	if s.Tags, err = m.snippetTags(s.ID); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// This will return the 10 most recently created snippets.
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	return m.List(models.ListQuery{Limit: 10})
+}
+
+// This will return up to q.Limit public snippets which haven't expired, in
+// the order asked for by q.Sort. SQLite stores times as text and compares
+// them as strings, so the cursor's time has to be formatted the same way
+// datetime() formats them.
+func (m *SnippetModel) List(q models.ListQuery) ([]*models.Snippet, error) {
+	field, desc, cursor := q.Order()
+	column := "s." + field
+	cmp, dir := ">", "ASC"
+	if desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE (s.expires IS NULL OR s.expires > datetime('now')) AND s.visibility = 'public'`
+
+	// Snippets which never expire have no place in a list sorted by expiry.
+	if field == "expires" {
+		stmt += ` AND s.expires IS NOT NULL`
+	}
+
+	args := []interface{}{}
+	if q.Tag != "" {
+		stmt += ` AND s.id IN (SELECT st.snippet_id FROM snippet_tags st JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+		args = append(args, q.Tag)
+	}
+	if cursor != nil {
+		t := cursor.Time.UTC().Format(timeFormat)
+		stmt += fmt.Sprintf(` AND (%[1]s %[2]s ? OR (%[1]s = ? AND s.id %[2]s ?))`, column, cmp)
+		args = append(args, t, t, cursor.ID)
+	}
+	stmt += fmt.Sprintf(` ORDER BY %[1]s %[2]s, s.id %[2]s LIMIT ?`, column, dir)
+	args = append(args, q.Limit)
+
+	snippets, err := m.query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Before != nil {
+		models.Reverse(snippets)
+	}
+	return snippets, nil
+}
+
+// This will return every snippet created by the given user, newest first,
+// including the ones which have already expired.
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 WHERE s.user_id = ? ORDER BY s.created DESC, s.id DESC`
+
+	return m.query(stmt, userID)
+}
+
+// This will change the title and content of an existing snippet, and record
+// the result as a new revision made by the given user.
+func (m *SnippetModel) Update(id, userID int, title, content string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = ?, content = ? WHERE id = ?`
+	if _, err := tx.Exec(stmt, title, content, id); err != nil {
+		return err
+	}
+
+	var number int
+	stmt = `SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`
+	if err := tx.QueryRow(stmt, id).Scan(&number); err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+			VALUES(?, ?, ?, ?, ?, datetime('now'))`
+	if _, err := tx.Exec(stmt, id, number, userID, title, content); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// This will change when a snippet expires, to the given time, or never if
+// it is zero. It works on snippets which have already expired too, as long
+// as they haven't been purged, so that their owner can renew them. If there
+// is no snippet with the given id, ErrNoRecord is returned.
+func (m *SnippetModel) Extend(id int, expires time.Time) error {
+	expiresAt := sql.NullString{String: expires.UTC().Format(timeFormat), Valid: !expires.IsZero()}
+
+	rs, err := m.DB.Exec(`UPDATE snippets SET expires = ? WHERE id = ?`, expiresAt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will remove a snippet, its revisions, tags and stars from the
+// database. If there is no snippet with the given id, ErrNoRecord is
+// returned.
+func (m *SnippetModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ?`, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM stars WHERE snippet_id = ?`, id); err != nil {
+		return err
+	}
+
+	rs, err := tx.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return tx.Commit()
+}
+
+// This will remove up to limit snippets which have expired, oldest expiry
+// first, along with their revisions, tags and stars, and return how many it
+// removed. If archive is true, each snippet is copied to the
+// snippets_archive table before it's deleted. The batch is handled in a
+// single transaction.
+func (m *SnippetModel) PurgeExpired(limit int, archive bool) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT id FROM snippets WHERE expires <= datetime('now') ORDER BY expires, id LIMIT ?`
+	rows, err := tx.Query(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	ids := []interface{}{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	if archive {
+		stmt = `INSERT INTO snippets_archive (id, user_id, slug, title, content, language, visibility, encrypted, created, expires, archived)
+				SELECT id, user_id, slug, title, content, language, visibility, encrypted, created, expires, datetime('now')
+				FROM snippets WHERE id IN (` + in + `)`
+		if _, err := tx.Exec(stmt, ids...); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id IN (`+in+`)`, ids...); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id IN (`+in+`)`, ids...); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM stars WHERE snippet_id IN (`+in+`)`, ids...); err != nil {
+		return 0, err
+	}
+
+	rs, err := tx.Exec(`DELETE FROM snippets WHERE id IN (`+in+`)`, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), tx.Commit()
+}
+
+// This will mark a burn-after-reading snippet as read. The read_at column
+// is only set if it's still NULL, so when several requests race to read the
+// snippet exactly one of them succeeds, and the others get ErrAlreadyRead.
+func (m *SnippetModel) MarkRead(id int) error {
+	stmt := `UPDATE snippets SET read_at = datetime('now') WHERE id = ? AND read_at IS NULL`
+
+	rs, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := rs.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrAlreadyRead
+	}
+
+	return nil
+}
+
+// This will check the password of a protected snippet, the same way that
+// UserModel.Authenticate checks a user's. If the password is wrong it
+// returns ErrInvalidCredentials, and if the snippet doesn't exist or isn't
+// protected it returns ErrNoRecord.
+func (m *SnippetModel) Unlock(id int, password string) error {
+	var hashedPassword []byte
+	row := m.DB.QueryRow("SELECT hashed_password FROM snippets WHERE id = ? AND hashed_password IS NOT NULL", id)
+	err := row.Scan(&hashedPassword)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return models.ErrInvalidCredentials
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// This will return the number of live snippets which were forked from the
+// given one.
+func (m *SnippetModel) CountForks(id int) (int, error) {
+	stmt := `SELECT COUNT(*) FROM snippets WHERE forked_from = ? AND (expires IS NULL OR expires > datetime('now'))`
+
+	var n int
+	if err := m.DB.QueryRow(stmt, id).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// query runs a SELECT statement built from snippetColumns and returns the
+// resulting snippets.
+func (m *SnippetModel) query(stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// The scanner interface is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSnippet reads the columns listed in snippetColumns into a new Snippet.
+func scanSnippet(row scanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var expires, readAt sql.NullTime
+
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.Slug,
+		&s.Author,
+		&s.Title,
+		&s.Content,
+		&s.Language,
+		&s.Visibility,
+		&s.Created,
+		&expires,
+		&s.BurnAfterReading,
+		&readAt,
+		&s.Protected,
+		&s.Encrypted,
+		&s.ForkedFrom,
+		&s.Stars,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.Expires = expires.Time
+	s.ReadAt = readAt.Time
+
+	return s, nil
+}
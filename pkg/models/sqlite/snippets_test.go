@@ -0,0 +1,526 @@
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	// Times are stored to the second.
+	expires := time.Now().AddDate(0, 0, 7).UTC().Truncate(time.Second)
+	id, err := m.Insert(1, "Title", "Content", "go", expires, models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Title" || s.Content != "Content" || s.Language != "go" {
+		t.Errorf("unexpected snippet %+v", s)
+	}
+	if !s.Expires.Equal(expires) {
+		t.Errorf("want snippet to expire at %v; got %v", expires, s.Expires)
+	}
+
+	// Expired snippets must be filtered out.
+	if _, err := db.Exec("UPDATE snippets SET expires = datetime('now', '-1 minute') WHERE id = ?", id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(id); err != models.ErrNoRecord {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func TestSnippetModelNeverExpires(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+
+	never, err := m.Insert(1, "Forever", "Content", "", time.Time{}, models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiring, err := m.Insert(1, "Fleeting", "Content", "", time.Now().Add(time.Hour), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(never)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Expires.IsZero() || s.Expired() {
+		t.Errorf("want a snippet which never expires; got expiry %v", s.Expires)
+	}
+
+	// It's listed with the newest snippets, but not with the ones which are
+	// about to expire, and the sweeper leaves it alone.
+	latest, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 2 {
+		t.Errorf("want 2 snippets in Latest; got %d", len(latest))
+	}
+
+	expiringSoon, err := m.List(models.ListQuery{Sort: models.SortExpiring, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiringSoon) != 1 || expiringSoon[0].ID != expiring {
+		t.Errorf("want only snippet #%d sorted by expiry; got %d snippets", expiring, len(expiringSoon))
+	}
+
+	if n, err := m.PurgeExpired(10, false); err != nil || n != 0 {
+		t.Errorf("want nothing purged; got %d, %v", n, err)
+	}
+}
+
+func TestSnippetModelExtend(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	id, err := m.Insert(1, "Title", "Content", "", time.Now().Add(-time.Minute), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(id); err != models.ErrNoRecord {
+		t.Fatalf("want the snippet to have expired; got %v", err)
+	}
+
+	// Its owner can still find it by its slug, but nobody else can.
+	rows, err := m.ByUser(1)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("want the expired snippet; got %v, %v", rows, err)
+	}
+	if s, err := m.GetOwnedBySlug(rows[0].Slug, 1); err != nil || s.ID != id {
+		t.Errorf("want snippet %d; got %v, %v", id, s, err)
+	}
+	if _, err := m.GetOwnedBySlug(rows[0].Slug, 2); err != models.ErrNoRecord {
+		t.Errorf("want %v for another user; got %v", models.ErrNoRecord, err)
+	}
+
+	// Extending an expired snippet brings it back.
+	expires := time.Now().AddDate(0, 0, 7).UTC().Truncate(time.Second)
+	if err := m.Extend(id, expires); err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Expires.Equal(expires) {
+		t.Errorf("want the snippet to expire at %v; got %v", expires, s.Expires)
+	}
+
+	if err := m.Extend(id, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := m.Get(id); err != nil || !s.Expires.IsZero() {
+		t.Errorf("want the snippet to never expire; got %v, %v", s, err)
+	}
+
+	if err := m.Extend(id+1, expires); err != models.ErrNoRecord {
+		t.Errorf("want %v for a missing snippet; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func TestSnippetModelForks(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+
+	expires := time.Now().AddDate(0, 0, 7)
+	original, err := m.Insert(1, "Title", "Content", "", expires, models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := m.Get(original); err != nil || s.ForkedFrom != 0 {
+		t.Fatalf("want a snippet which isn't a fork; got %v, %v", s, err)
+	}
+
+	fork, err := m.Insert(2, "Title", "Content", "", expires, models.SnippetOptions{ForkedFrom: original})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.Get(fork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ForkedFrom != original {
+		t.Errorf("want the snippet to be forked from %d; got %d", original, s.ForkedFrom)
+	}
+
+	// Expired forks aren't counted.
+	if _, err := m.Insert(3, "Title", "Content", "", time.Now().Add(-time.Minute), models.SnippetOptions{ForkedFrom: original}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.CountForks(original); err != nil || n != 1 {
+		t.Errorf("want 1 fork; got %d, %v", n, err)
+	}
+	if n, err := m.CountForks(fork); err != nil || n != 0 {
+		t.Errorf("want 0 forks; got %d, %v", n, err)
+	}
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+
+	for _, days := range []int{365, 7, 1} {
+		expires := time.Now().AddDate(0, 0, days)
+		if _, err := m.Insert(1, "Title", "Content", "", expires, models.SnippetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snippets, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 3 {
+		t.Fatalf("want 3 snippets; got %d", len(snippets))
+	}
+	if snippets[0].ID != 3 {
+		t.Errorf("want newest snippet first; got #%d", snippets[0].ID)
+	}
+}
+
+func TestSnippetModelList(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	// The snippets are all created in the same second, so the ids have to
+	// break the ties between them.
+	for i := 0; i < 5; i++ {
+		if _, err := m.Insert(1, "Title", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("UPDATE snippets SET expires = datetime('now', '+1 day') WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE snippets SET expires = datetime('now', '+2 days') WHERE id = 4"); err != nil {
+		t.Fatal(err)
+	}
+
+	cursor := func(id int, sort string) *models.Cursor {
+		s, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := models.NewCursor(s, sort)
+		return &c
+	}
+
+	tests := []struct {
+		name    string
+		q       models.ListQuery
+		wantIDs []int
+	}{
+		{"First page", models.ListQuery{Limit: 2}, []int{5, 4}},
+		{"Next page", models.ListQuery{After: cursor(4, models.SortNewest), Limit: 2}, []int{3, 2}},
+		{"Last page", models.ListQuery{After: cursor(2, models.SortNewest), Limit: 2}, []int{1}},
+		{"Past the end", models.ListQuery{After: cursor(1, models.SortNewest), Limit: 2}, []int{}},
+		{"Previous page", models.ListQuery{Before: cursor(3, models.SortNewest), Limit: 2}, []int{5, 4}},
+		{"Oldest", models.ListQuery{Sort: models.SortOldest, Limit: 2}, []int{1, 2}},
+		{"Oldest next page", models.ListQuery{Sort: models.SortOldest, After: cursor(2, models.SortOldest), Limit: 2}, []int{3, 4}},
+		{"Expiring", models.ListQuery{Sort: models.SortExpiring, Limit: 3}, []int{2, 4, 1}},
+		{"Expiring next page", models.ListQuery{Sort: models.SortExpiring, After: cursor(4, models.SortExpiring), Limit: 2}, []int{1, 3}},
+		{"Expiring previous page", models.ListQuery{Sort: models.SortExpiring, Before: cursor(1, models.SortExpiring), Limit: 5}, []int{2, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.List(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []int{}
+			for _, s := range snippets {
+				ids = append(ids, s.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("want %v; got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
+func TestSnippetModelByUser(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+	users := UserModel{db}
+
+	if err := users.Insert("Alice Jones", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := m.Insert(1, "Live", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := m.Insert(1, "Expired", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE snippets SET expires = datetime('now', '-1 minute') WHERE id = ?", expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Insert(2, "Someone else's", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both of Alice's snippets should be listed, expired or not, with her
+	// name filled in as the author.
+	snippets, err := m.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 2 {
+		t.Fatalf("want 2 snippets; got %d", len(snippets))
+	}
+	if snippets[0].ID != expired || snippets[1].ID != live {
+		t.Errorf("want snippets #%d and #%d; got #%d and #%d", expired, live, snippets[0].ID, snippets[1].ID)
+	}
+	if snippets[1].Author != "Alice Jones" {
+		t.Errorf("want author %q; got %q", "Alice Jones", snippets[1].Author)
+	}
+}
+
+func TestSnippetModelRevisions(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+
+	id, err := m.Insert(1, "First", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Update(id, 1, "Second", "Changed content"); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := m.Revisions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("want 2 revisions; got %d", len(revisions))
+	}
+	if revisions[0].Number != 2 || revisions[0].Title != "Second" {
+		t.Errorf("want newest revision first; got %+v", revisions[0])
+	}
+
+	r, err := m.Revision(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Title != "First" || r.Content != "Content" {
+		t.Errorf("unexpected first revision %+v", r)
+	}
+
+	// Deleting the snippet removes its revisions too.
+	if err := m.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Revision(id, 1); err != models.ErrNoRecord {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func TestSnippetModelVisibility(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+
+	ids := map[string]int{}
+	for _, v := range []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate} {
+		id, err := m.Insert(1, "Hidden treasure", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{Visibility: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.SetTags(id, []string{"treasure"}); err != nil {
+			t.Fatal(err)
+		}
+		ids[v] = id
+	}
+
+	// Every snippet can be fetched by its id or slug, whatever its
+	// visibility; it's up to the handlers to decide who may see it.
+	slugs := map[string]bool{}
+	for v, id := range ids {
+		s, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Visibility != v {
+			t.Errorf("want visibility %q; got %q", v, s.Visibility)
+		}
+		if len(s.Slug) != 12 || slugs[s.Slug] {
+			t.Errorf("want a new 12 character slug; got %q", s.Slug)
+		}
+		slugs[s.Slug] = true
+
+		bySlug, err := m.GetBySlug(s.Slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bySlug.ID != id {
+			t.Errorf("want snippet #%d by slug; got #%d", id, bySlug.ID)
+		}
+	}
+
+	if _, err := m.GetBySlug("no-such-slug"); err != models.ErrNoRecord {
+		t.Errorf("want ErrNoRecord for an unknown slug; got %v", err)
+	}
+
+	// Only the public snippet is listed, searchable and counted by tag.
+	latest, err := m.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].ID != ids[models.VisibilityPublic] {
+		t.Errorf("want only the public snippet in Latest; got %d snippets", len(latest))
+	}
+
+	found, err := m.Search(models.SearchQuery{Text: "treasure"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != ids[models.VisibilityPublic] {
+		t.Errorf("want only the public snippet in Search; got %d snippets", len(found))
+	}
+
+	tags, err := m.Tags(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Count != 1 {
+		t.Errorf("want the tag to count only the public snippet; got %v", tags)
+	}
+}
+
+func TestSnippetModelMarkRead(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+
+	id, err := m.Insert(1, "Database password", "hunter2", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{Visibility: models.VisibilityUnlisted, BurnAfterReading: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.BurnAfterReading || !s.ReadAt.IsZero() {
+		t.Fatalf("want an unread burn-after-reading snippet; got %+v", s)
+	}
+
+	// Only the first read succeeds.
+	if err := m.MarkRead(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MarkRead(id); err != models.ErrAlreadyRead {
+		t.Errorf("want ErrAlreadyRead on the second read; got %v", err)
+	}
+
+	s, err = m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ReadAt.IsZero() {
+		t.Errorf("want the read time to be recorded")
+	}
+}
+
+func TestSnippetModelUnlock(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+
+	protected, err := m.Insert(1, "Staging credentials", "hunter2", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unprotected, err := m.Insert(1, "Hello", "World", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(protected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Protected {
+		t.Errorf("want the snippet to be protected")
+	}
+
+	tests := []struct {
+		name     string
+		id       int
+		password string
+		wantErr  error
+	}{
+		{"Right password", protected, "correct horse", nil},
+		{"Wrong password", protected, "battery staple", models.ErrInvalidCredentials},
+		{"No password", unprotected, "", models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.Unlock(tt.id, tt.password); err != tt.wantErr {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSnippetModelPurgeExpired(t *testing.T) {
+	for _, archive := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Archive %t", archive), func(t *testing.T) {
+			db := newTestDB(t)
+			m := SnippetModel{db}
+
+			for i := 0; i < 5; i++ {
+				id, err := m.Insert(1, "Title", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := m.SetTags(id, []string{"old"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := db.Exec("UPDATE snippets SET expires = datetime('now', '-1 minute') WHERE id <= 3"); err != nil {
+				t.Fatal(err)
+			}
+
+			// The first batch is full, and the second picks up the rest.
+			for _, want := range []int{2, 1, 0} {
+				n, err := m.PurgeExpired(2, archive)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != want {
+					t.Errorf("want %d snippets purged; got %d", want, n)
+				}
+			}
+
+			var snippets, revisions, tags, archived int
+			row := db.QueryRow(`SELECT (SELECT COUNT(*) FROM snippets), (SELECT COUNT(*) FROM snippet_revisions),
+				(SELECT COUNT(*) FROM snippet_tags), (SELECT COUNT(*) FROM snippets_archive)`)
+			if err := row.Scan(&snippets, &revisions, &tags, &archived); err != nil {
+				t.Fatal(err)
+			}
+			if snippets != 2 || revisions != 2 || tags != 2 {
+				t.Errorf("want 2 snippets, revisions and tags left; got %d, %d and %d", snippets, revisions, tags)
+			}
+
+			wantArchived := 0
+			if archive {
+				wantArchived = 3
+			}
+			if archived != wantArchived {
+				t.Errorf("want %d archived snippets; got %d", wantArchived, archived)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+// This will star a snippet for the given user. Each user can only star a
+// snippet once, so starring it again does nothing.
+func (m *SnippetModel) Star(userID, snippetID int) error {
+	stmt := `INSERT OR IGNORE INTO stars (snippet_id, user_id, created) VALUES(?, ?, datetime('now'))`
+
+	_, err := m.DB.Exec(stmt, snippetID, userID)
+	return err
+}
+
+// This will remove the user's star from a snippet, if they had starred it.
+func (m *SnippetModel) Unstar(userID, snippetID int) error {
+	_, err := m.DB.Exec(`DELETE FROM stars WHERE snippet_id = ? AND user_id = ?`, snippetID, userID)
+	return err
+}
+
+// This will report whether the user has starred a snippet.
+func (m *SnippetModel) IsStarred(userID, snippetID int) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT 1 FROM stars WHERE snippet_id = ? AND user_id = ?)`
+
+	var starred bool
+	err := m.DB.QueryRow(stmt, snippetID, userID).Scan(&starred)
+	return starred, err
+}
+
+// This will return the live snippets which the user has starred, most
+// recently starred first.
+func (m *SnippetModel) StarredBy(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 JOIN stars st ON st.snippet_id = s.id
+			 WHERE st.user_id = ? AND (s.expires IS NULL OR s.expires > datetime('now'))
+			 ORDER BY st.created DESC, s.id DESC`
+
+	return m.query(stmt, userID)
+}
+
+// This will return up to limit live public snippets with the most stars
+// given to them since the given time, most starred first. SQLite compares
+// times as strings, so since is formatted the same way datetime() formats
+// them.
+func (m *SnippetModel) MostStarred(since time.Time, limit int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+			 JOIN (SELECT snippet_id, COUNT(*) AS n FROM stars WHERE created > ? GROUP BY snippet_id) w ON w.snippet_id = s.id
+			 WHERE (s.expires IS NULL OR s.expires > datetime('now')) AND s.visibility = 'public'
+			 ORDER BY w.n DESC, s.id DESC LIMIT ?`
+
+	return m.query(stmt, since.UTC().Format(timeFormat), limit)
+}
@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestSnippetModelStars(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	expires := time.Now().AddDate(0, 0, 7)
+	popular, err := m.Insert(1, "Popular", "Content", "", expires, models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quiet, err := m.Insert(1, "Quiet", "Content", "", expires, models.SnippetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := m.Insert(1, "Private", "Content", "", expires, models.SnippetOptions{Visibility: models.VisibilityPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Starring a snippet twice only counts once.
+	for _, star := range []struct{ userID, snippetID int }{
+		{2, popular}, {3, popular}, {3, popular}, {2, quiet}, {1, private}, {2, private}, {3, private},
+	} {
+		if err := m.Star(star.userID, star.snippetID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := m.Get(popular)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Stars != 2 {
+		t.Errorf("want 2 stars; got %d", s.Stars)
+	}
+
+	if starred, err := m.IsStarred(3, popular); err != nil || !starred {
+		t.Errorf("want user 3 to have starred the snippet; got %v, %v", starred, err)
+	}
+	if starred, err := m.IsStarred(3, quiet); err != nil || starred {
+		t.Errorf("want user 3 not to have starred the snippet; got %v, %v", starred, err)
+	}
+
+	// Private snippets are left out of the most starred, however many stars
+	// they have.
+	snippets, err := m.MostStarred(time.Now().AddDate(0, 0, -7), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 2 || snippets[0].ID != popular || snippets[1].ID != quiet {
+		t.Errorf("want the popular then the quiet snippet; got %v", snippets)
+	}
+
+	// Stars from before the given time don't count.
+	if _, err := db.Exec(`UPDATE stars SET created = datetime('now', '-8 days') WHERE snippet_id = ?`, popular); err != nil {
+		t.Fatal(err)
+	}
+	snippets, err = m.MostStarred(time.Now().AddDate(0, 0, -7), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 1 || snippets[0].ID != quiet {
+		t.Errorf("want just the quiet snippet; got %v", snippets)
+	}
+
+	if err := m.Unstar(2, quiet); err != nil {
+		t.Fatal(err)
+	}
+	snippets, err = m.StarredBy(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 2 {
+		t.Errorf("want 2 starred snippets; got %d", len(snippets))
+	}
+
+	// Deleting a snippet removes its stars too.
+	if err := m.Delete(popular); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM stars WHERE snippet_id = ?`, popular).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want no stars left; got %d", n)
+	}
+}
@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestSnippetModelTags(t *testing.T) {
+	db := newTestDB(t)
+	m := SnippetModel{db}
+
+	for i := 0; i < 4; i++ {
+		if _, err := m.Insert(1, "Title", "Content", "", time.Now().AddDate(0, 0, 7), models.SnippetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tags := map[int][]string{
+		1: {"sql", "k8s"},
+		2: {"k8s"},
+		3: {"bash", "k8s"},
+		4: {"sql"},
+	}
+	for id, names := range tags {
+		if err := m.SetTags(id, names); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Setting the tags again replaces the old ones rather than adding to
+	// them, and reuses the existing rows in the tags table.
+	if err := m.SetTags(3, []string{"bash"}); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM tags").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("want 3 rows in tags; got %d", count)
+	}
+
+	s, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(s.Tags) != "[k8s sql]" {
+		t.Errorf("want tags [k8s sql]; got %v", s.Tags)
+	}
+
+	// Expired snippets don't count towards the cloud or show up under
+	// their tags.
+	if _, err := db.Exec("UPDATE snippets SET expires = datetime('now', '-1 minute') WHERE id = 4"); err != nil {
+		t.Fatal(err)
+	}
+
+	cloud, err := m.Tags(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, tag := range cloud {
+		got = append(got, fmt.Sprintf("%s:%d", tag.Name, tag.Count))
+	}
+	if fmt.Sprint(got) != "[bash:1 k8s:2]" {
+		t.Errorf("want tag cloud [bash:1 k8s:2]; got %v", got)
+	}
+
+	snippets, err := m.List(models.ListQuery{Tag: "sql", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 1 || snippets[0].ID != 1 {
+		t.Errorf("want only snippet #1 tagged sql; got %d snippets", len(snippets))
+	}
+
+	// Deleting a snippet removes its tags too.
+	if err := m.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM snippet_tags WHERE snippet_id = 1").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("want no tags left for a deleted snippet; got %d", count)
+	}
+}
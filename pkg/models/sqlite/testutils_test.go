@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/petrostrak/code-snippet/migrations"
+)
+
+// newTestDB opens a fresh SQLite database in a temporary directory and runs
+// the embedded migrations against it. The database is closed when the test
+// finishes.
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m := &migrations.Migrator{DB: db, Driver: "sqlite3"}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestTokenModel(t *testing.T) {
+	db := newTestDB(t)
+	m := TokenModel{db}
+	users := UserModel{db}
+
+	if err := users.Insert("Alice Jones", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := m.Insert(1, "Valid", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := m.Insert(1, "Expired", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	never, err := m.Insert(1, "Never", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token itself must not be stored.
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM api_tokens WHERE hash = ?", valid).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want the token to be stored hashed")
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantID  int
+		wantErr error
+	}{
+		{"Valid", valid, 1, nil},
+		{"Never expires", never, 1, nil},
+		{"Expired", expired, 0, models.ErrInvalidCredentials},
+		{"Unknown", "not-a-token", 0, models.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := m.Authenticate(tt.token)
+			if id != tt.wantID || err != tt.wantErr {
+				t.Errorf("want %d, %v; got %d, %v", tt.wantID, tt.wantErr, id, err)
+			}
+		})
+	}
+
+	tokens, err := m.ByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 3 || tokens[0].Name != "Never" {
+		t.Fatalf("want 3 tokens, newest first; got %+v", tokens)
+	}
+	if !tokens[0].Expires.IsZero() || tokens[0].LastUsed.IsZero() {
+		t.Errorf("want no expiry and a last used time; got %+v", tokens[0])
+	}
+	if !tokens[1].Expired() || !tokens[1].LastUsed.IsZero() {
+		t.Errorf("want an expired, unused token; got %+v", tokens[1])
+	}
+
+	// Tokens can only be revoked by their owner.
+	if err := m.Delete(1, 2); err != models.ErrNoRecord {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+	if err := m.Delete(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Authenticate(valid); err != models.ErrInvalidCredentials {
+		t.Errorf("want a revoked token to be rejected; got %v", err)
+	}
+}
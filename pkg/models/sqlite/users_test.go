@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/petrostrak/code-snippet/pkg/models"
+)
+
+func TestUserModelInsert(t *testing.T) {
+	m := UserModel{newTestDB(t)}
+
+	if err := m.Insert("Alice Jones", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Inserting the same email a second time must be reported as a
+	// duplicate, not as a raw driver error.
+	err := m.Insert("Alice Smith", "alice@example.com", "validPa$$word")
+	if err != models.ErrDuplicateEmail {
+		t.Errorf("want %v; got %v", models.ErrDuplicateEmail, err)
+	}
+}
+
+func TestUserModelAuthenticate(t *testing.T) {
+	m := UserModel{newTestDB(t)}
+
+	if err := m.Insert("Alice Jones", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{"Valid", "alice@example.com", "validPa$$word", 1, nil},
+		{"Wrong password", "alice@example.com", "wrongPa$$word", 0, models.ErrInvalidCredentials},
+		{"Unknown email", "bob@example.com", "validPa$$word", 0, models.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := m.Authenticate(tt.email, tt.password)
+
+			if id != tt.wantID {
+				t.Errorf("want %d; got %d", tt.wantID, id)
+			}
+
+			if err != tt.wantErr {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package search holds the helpers for showing full-text search results: it
+// splits a query into terms and marks those terms up in the matching text.
+// The searching itself is done by the storage backends.
+package search
+
+import (
+	"html/template"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Terms splits a search query into its lower-cased terms, dropping
+// punctuation around each word and any duplicates.
+func Terms(query string) []string {
+	var terms []string
+	seen := map[string]bool{}
+
+	for _, f := range strings.Fields(strings.ToLower(query)) {
+		f = strings.TrimFunc(f, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		terms = append(terms, f)
+	}
+
+	return terms
+}
+
+// Highlight returns text HTML-escaped, with every occurrence of the terms
+// wrapped in a <mark> element. Matching ignores case.
+func Highlight(text string, terms []string) template.HTML {
+	var b strings.Builder
+	lower := strings.ToLower(text)
+
+	// Lower-casing can change the length of some characters, in which case
+	// the offsets we find in lower wouldn't line up with text. That's rare
+	// enough that we simply don't mark anything up.
+	if len(lower) != len(text) {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	for i := 0; i < len(text); {
+		start, end := nextMatch(lower, i, terms)
+		if start < 0 {
+			b.WriteString(template.HTMLEscapeString(text[i:]))
+			break
+		}
+
+		b.WriteString(template.HTMLEscapeString(text[i:start]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[start:end]))
+		b.WriteString("</mark>")
+		i = end
+	}
+
+	return template.HTML(b.String())
+}
+
+// Excerpt returns a highlighted part of text, around size characters long,
+// which starts a little before the first match of any term. If there is no
+// match, the excerpt is taken from the start of the text.
+func Excerpt(text string, terms []string, size int) template.HTML {
+	start, _ := nextMatch(strings.ToLower(text), 0, terms)
+	if start < 0 || len(strings.ToLower(text)) != len(text) {
+		start = 0
+	}
+
+	// Back up by a quarter of the excerpt, to a word boundary, so that the
+	// match is shown in context.
+	from := start - size/4
+	if from <= 0 {
+		from = 0
+	} else {
+		if i := strings.LastIndexAny(text[:from], " \t\n"); i >= 0 {
+			from = i + 1
+		}
+		for from < len(text) && !utf8.RuneStart(text[from]) {
+			from++
+		}
+	}
+
+	to := from
+	for n := 0; to < len(text) && n < size; n++ {
+		_, w := utf8.DecodeRuneInString(text[to:])
+		to += w
+	}
+
+	excerpt := strings.TrimSpace(text[from:to])
+	if from > 0 {
+		excerpt = "…" + excerpt
+	}
+	if to < len(text) {
+		excerpt += "…"
+	}
+
+	return Highlight(excerpt, terms)
+}
+
+// nextMatch returns the byte offsets of the earliest occurrence of any of the
+// terms in lower at or after i, preferring the longest term when several
+// start at the same place. It returns -1, -1 when nothing matches.
+func nextMatch(lower string, i int, terms []string) (int, int) {
+	start, end := -1, -1
+	for _, t := range terms {
+		if t == "" {
+			continue
+		}
+		j := strings.Index(lower[i:], t)
+		if j < 0 {
+			continue
+		}
+		j += i
+		if start < 0 || j < start || (j == start && j+len(t) > end) {
+			start, end = j, j+len(t)
+		}
+	}
+	return start, end
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Words", "Docker  compose", []string{"docker", "compose"}},
+		{"Punctuation", `"nginx", (reload)!`, []string{"nginx", "reload"}},
+		{"Duplicates", "go Go GO", []string{"go"}},
+		{"Inner punctuation", "kubectl-apply", []string{"kubectl-apply"}},
+		{"Empty", "  -- ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Terms(tt.query)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Single term", "Restart nginx now", []string{"nginx"}, "Restart <mark>nginx</mark> now"},
+		{"Ignores case", "NGINX and Nginx", []string{"nginx"}, "<mark>NGINX</mark> and <mark>Nginx</mark>"},
+		{"Longest term wins", "postgresql", []string{"postgres", "postgresql"}, "<mark>postgresql</mark>"},
+		{"Escapes text", "<b>go</b>", []string{"go"}, "&lt;b&gt;<mark>go</mark>&lt;/b&gt;"},
+		{"No terms", "a < b", nil, "a &lt; b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Highlight(tt.text, tt.terms))
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		size  int
+		want  string
+	}{
+		{"Short text", "echo hello", []string{"hello"}, 40, "echo <mark>hello</mark>"},
+		{"No match", "one two three four", []string{"five"}, 8, "one two…"},
+		{"Match later on", "one two three four five six seven eight", []string{"six"}, 16, "…five <mark>six</mark> seven e…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Excerpt(tt.text, tt.terms, tt.size))
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
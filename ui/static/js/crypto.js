@@ -0,0 +1,76 @@
+// Encrypted snippets are encrypted and decrypted here, in the browser, with
+// AES-GCM. The server only ever sees the ciphertext: the key lives in the
+// URL fragment (the part after the #), which browsers never send to the
+// server. The ciphertext is the random 12 byte IV followed by the encrypted
+// content, in unpadded URL-safe base64, and so is the key.
+(function () {
+	if (!window.crypto || !window.crypto.subtle) {
+		return;
+	}
+
+	function encode(bytes) {
+		var s = "";
+		for (var i = 0; i < bytes.length; i++) {
+			s += String.fromCharCode(bytes[i]);
+		}
+		return btoa(s).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+	}
+
+	function decode(s) {
+		var bin = atob(s.replace(/-/g, "+").replace(/_/g, "/"));
+		var bytes = new Uint8Array(bin.length);
+		for (var i = 0; i < bin.length; i++) {
+			bytes[i] = bin.charCodeAt(i);
+		}
+		return bytes;
+	}
+
+	// On the create page, encrypt the content when the snippet is submitted
+	// with the encrypt box ticked. The key goes in the fragment of the form's
+	// action, and browsers carry it over to the page we're redirected to.
+	var form = document.querySelector("form.create-snippet");
+	if (form) {
+		form.addEventListener("submit", function (e) {
+			var box = form.querySelector("input[name='encrypted']");
+			var content = form.querySelector("textarea[name='content']");
+			if (!box.checked || content.value === "" || form.dataset.encrypted) {
+				return;
+			}
+			e.preventDefault();
+
+			var iv = window.crypto.getRandomValues(new Uint8Array(12));
+			var key;
+			window.crypto.subtle.generateKey({name: "AES-GCM", length: 256}, true, ["encrypt"]).then(function (k) {
+				key = k;
+				return window.crypto.subtle.encrypt({name: "AES-GCM", iv: iv}, key, new TextEncoder().encode(content.value));
+			}).then(function (ciphertext) {
+				var data = new Uint8Array(iv.length + ciphertext.byteLength);
+				data.set(iv);
+				data.set(new Uint8Array(ciphertext), iv.length);
+				content.value = encode(data);
+				content.readOnly = true;
+				return window.crypto.subtle.exportKey("raw", key);
+			}).then(function (raw) {
+				form.action = "/snippet/create#" + encode(new Uint8Array(raw));
+				form.dataset.encrypted = "true";
+				form.submit();
+			});
+		});
+	}
+
+	// On an encrypted snippet's page, decrypt the content with the key from
+	// the fragment. If there's no key, or it's the wrong one, the message
+	// which the server put there stays.
+	var pre = document.querySelector("pre.encrypted");
+	var fragment = window.location.hash.slice(1);
+	if (pre && fragment) {
+		var data = decode(pre.dataset.ciphertext);
+		window.crypto.subtle.importKey("raw", decode(fragment), "AES-GCM", false, ["decrypt"]).then(function (key) {
+			return window.crypto.subtle.decrypt({name: "AES-GCM", iv: data.slice(0, 12)}, key, data.slice(12));
+		}).then(function (plaintext) {
+			pre.querySelector("code").textContent = new TextDecoder().decode(plaintext);
+		}).catch(function () {
+			pre.querySelector("code").textContent = "This snippet couldn't be decrypted. Check that you have the whole link.";
+		});
+	}
+})();